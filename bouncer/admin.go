@@ -0,0 +1,648 @@
+package bouncer
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Scopes that can be granted to admin tokens. Each scope allows reading and
+// changing one kind of resource.
+const (
+	// AdminScopeAliases allows changing aliases.
+	AdminScopeAliases = "aliases"
+	// AdminScopeProducts allows changing products and their languages.
+	AdminScopeProducts = "products"
+	// AdminScopeLocations allows reading and changing the locations of
+	// products.
+	AdminScopeLocations = "locations"
+	// AdminScopeExperiments allows changing experiments.
+	AdminScopeExperiments = "experiments"
+	// AdminScopeAudit allows reading the audit log.
+	AdminScopeAudit = "audit"
+)
+
+const (
+	// maxAdminRequestBytes is the maximum size of an admin request body.
+	maxAdminRequestBytes = 64 << 10
+
+	// maxLocationPathLength is the size of mirror_locations.path.
+	maxLocationPathLength = 255
+)
+
+var (
+	knownAdminScopes = []string{AdminScopeAliases, AdminScopeProducts, AdminScopeLocations, AdminScopeExperiments, AdminScopeAudit}
+
+	// adminNameRegexp matches valid alias and product names.
+	adminNameRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._+-]{0,254}$`)
+	// adminLangRegexp matches valid languages, e.g. en-US or ja-JP-mac.
+	adminLangRegexp = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{1,8}){0,3}$`)
+)
+
+// AdminToken grants scopes to the bearer of a token.
+type AdminToken struct {
+	// Name identifies the bearer in the audit log, e.g. releng.
+	Name string `json:"name"`
+	// TokenSHA256 is the hex-encoded SHA-256 of the token, so that the config
+	// file doesn't hold the token itself.
+	TokenSHA256 string   `json:"token_sha256"`
+	Scopes      []string `json:"scopes"`
+}
+
+func (t *AdminToken) validate() error {
+	if t.Name == "" {
+		return errors.New("admin token: name must be set")
+	}
+	if sum, err := hex.DecodeString(t.TokenSHA256); err != nil || len(sum) != sha256.Size {
+		return fmt.Errorf("admin token %s: token_sha256 must be a hex-encoded SHA-256", t.Name)
+	}
+	for _, scope := range t.Scopes {
+		if !slices.Contains(knownAdminScopes, scope) {
+			return fmt.Errorf("admin token %s: unknown scope %s", t.Name, scope)
+		}
+	}
+	return nil
+}
+
+// matches returns whether token hashes to TokenSHA256.
+func (t *AdminToken) matches(token string) bool {
+	sum := sha256.Sum256([]byte(token))
+	expected, _ := hex.DecodeString(t.TokenSHA256)
+	return subtle.ConstantTimeCompare(sum[:], expected) == 1
+}
+
+// AdminHandler serves the JSON admin API under /admin/v1/, which changes the
+// catalog. It must be served on a separate, private listener. Every change is
+// recorded in the audit log along with the name of the token.
+type AdminHandler struct {
+	bouncer *BouncerHandler
+	tokens  []AdminToken
+	mux     *http.ServeMux
+}
+
+// NewAdminHandler returns an AdminHandler using the database of bouncer and
+// accepting the given tokens.
+func NewAdminHandler(bouncer *BouncerHandler, tokens []AdminToken) *AdminHandler {
+	h := &AdminHandler{
+		bouncer: bouncer,
+		tokens:  tokens,
+		mux:     http.NewServeMux(),
+	}
+	h.handle("PUT /admin/v1/aliases/{alias}", AdminScopeAliases, h.putAlias)
+	h.handle("DELETE /admin/v1/aliases/{alias}", AdminScopeAliases, h.deleteAlias)
+	h.handle("GET /admin/v1/aliases/{alias}/history", AdminScopeAliases, h.aliasHistory)
+	h.handle("POST /admin/v1/aliases/{alias}/rollback", AdminScopeAliases, h.rollbackAlias)
+	h.handle("GET /admin/v1/aliases/{alias}/rollout", AdminScopeAliases, h.rollout)
+	h.handle("PUT /admin/v1/aliases/{alias}/rollout", AdminScopeAliases, h.putRollout)
+	h.handle("DELETE /admin/v1/aliases/{alias}/rollout", AdminScopeAliases, h.deleteRollout)
+	h.handle("POST /admin/v1/alias-switches", AdminScopeAliases, h.switchAliases)
+	h.handle("GET /admin/v1/scheduled-switches", AdminScopeAliases, h.scheduledSwitches)
+	h.handle("POST /admin/v1/scheduled-switches", AdminScopeAliases, h.scheduleSwitch)
+	h.handle("GET /admin/v1/scheduled-switches/{id}", AdminScopeAliases, h.previewScheduledSwitch)
+	h.handle("DELETE /admin/v1/scheduled-switches/{id}", AdminScopeAliases, h.cancelScheduledSwitch)
+	h.handle("PUT /admin/v1/products/{product}", AdminScopeProducts, h.putProduct)
+	h.handle("DELETE /admin/v1/products/{product}", AdminScopeProducts, h.deleteProduct)
+	h.handle("PUT /admin/v1/products/{product}/languages/{lang}", AdminScopeProducts, h.putLanguage)
+	h.handle("DELETE /admin/v1/products/{product}/languages/{lang}", AdminScopeProducts, h.deleteLanguage)
+	h.handle("GET /admin/v1/products/{product}/locations", AdminScopeLocations, h.locations)
+	h.handle("PUT /admin/v1/products/{product}/locations/{os}", AdminScopeLocations, h.putLocation)
+	h.handle("DELETE /admin/v1/products/{product}/locations/{os}", AdminScopeLocations, h.deleteLocation)
+	h.handle("GET /admin/v1/experiments", AdminScopeExperiments, h.experiments)
+	h.handle("GET /admin/v1/experiments/{product}/{experiment}", AdminScopeExperiments, h.experiment)
+	h.handle("PUT /admin/v1/experiments/{product}/{experiment}", AdminScopeExperiments, h.putExperiment)
+	h.handle("DELETE /admin/v1/experiments/{product}/{experiment}", AdminScopeExperiments, h.deleteExperiment)
+	h.handle("GET /admin/v1/audit", AdminScopeAudit, h.auditLog)
+	return h
+}
+
+func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	h.mux.ServeHTTP(w, req)
+}
+
+// handle registers a handler that requires a token with scope. The handler is
+// given the name of the token.
+func (h *AdminHandler) handle(pattern, scope string, handler func(w http.ResponseWriter, req *http.Request, actor string)) {
+	h.mux.HandleFunc(pattern, func(w http.ResponseWriter, req *http.Request) {
+		token := h.authenticate(req)
+		if token == nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized.", http.StatusUnauthorized)
+			return
+		}
+		if !slices.Contains(token.Scopes, scope) {
+			http.Error(w, fmt.Sprintf("The token does not have the %s scope.", scope), http.StatusForbidden)
+			return
+		}
+		handler(w, req, token.Name)
+	})
+}
+
+// authenticate returns the token in the Authorization header, or nil when it
+// is missing or unknown.
+func (h *AdminHandler) authenticate(req *http.Request) *AdminToken {
+	token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return nil
+	}
+	for i := range h.tokens {
+		if h.tokens[i].matches(token) {
+			return &h.tokens[i]
+		}
+	}
+	return nil
+}
+
+type putAliasRequest struct {
+	Product string `json:"product"`
+}
+
+func (h *AdminHandler) putAlias(w http.ResponseWriter, req *http.Request, actor string) {
+	alias := req.PathValue("alias")
+	var body putAliasRequest
+	if !h.decode(w, req, &body) {
+		return
+	}
+	if err := validateAdminName("alias", alias); err != nil {
+		h.writeError(w, req, err)
+		return
+	}
+
+	created, err := h.bouncer.db.PutAlias(actor, alias, body.Product)
+	if err != nil {
+		h.writeError(w, req, err)
+		return
+	}
+	log.Printf("AdminHandler: %s set alias %s to %s", actor, alias, body.Product)
+	h.writeJSON(w, created, &Alias{Alias: alias, Product: body.Product})
+}
+
+func (h *AdminHandler) deleteAlias(w http.ResponseWriter, req *http.Request, actor string) {
+	alias := req.PathValue("alias")
+	if err := h.bouncer.db.DeleteAlias(actor, alias); err != nil {
+		h.writeError(w, req, err)
+		return
+	}
+	log.Printf("AdminHandler: %s deleted alias %s", actor, alias)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AdminHandler) aliasHistory(w http.ResponseWriter, req *http.Request, _ string) {
+	limit, offset, err := pagination(req.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entries, err := h.bouncer.db.AliasHistory(req.PathValue("alias"), limit+1, offset)
+	if err != nil {
+		h.writeError(w, req, err)
+		return
+	}
+	h.writeJSON(w, false, newPage(req, entries, limit, offset))
+}
+
+func (h *AdminHandler) rollbackAlias(w http.ResponseWriter, req *http.Request, actor string) {
+	alias := req.PathValue("alias")
+	product, err := h.bouncer.db.RollbackAlias(actor, alias)
+	if err != nil {
+		h.writeError(w, req, err)
+		return
+	}
+	log.Printf("AdminHandler: %s rolled back alias %s to %s", actor, alias, product)
+	h.writeJSON(w, false, &Alias{Alias: alias, Product: product})
+}
+
+func (h *AdminHandler) rollout(w http.ResponseWriter, req *http.Request, _ string) {
+	rollout, err := h.bouncer.db.Rollout(req.PathValue("alias"))
+	if err != nil {
+		h.writeError(w, req, err)
+		return
+	}
+	h.writeJSON(w, false, rollout)
+}
+
+type putRolloutRequest struct {
+	Targets []RolloutTarget `json:"targets"`
+}
+
+// RolloutResponse is the result of a change of a rollout. Changes lists the
+// change for the clients of each target.
+type RolloutResponse struct {
+	Rollout *Rollout      `json:"rollout"`
+	Changes []AliasChange `json:"changes"`
+}
+
+func (h *AdminHandler) putRollout(w http.ResponseWriter, req *http.Request, actor string) {
+	var body putRolloutRequest
+	if !h.decode(w, req, &body) {
+		return
+	}
+
+	rollout := &Rollout{Alias: req.PathValue("alias"), Targets: body.Targets}
+	changes, err := h.bouncer.PutRollout(actor, rollout)
+	if err != nil {
+		h.writeError(w, req, err)
+		return
+	}
+	log.Printf("AdminHandler: %s set the rollout of alias %s to %v", actor, rollout.Alias, rollout.Targets)
+	h.writeJSON(w, false, &RolloutResponse{Rollout: rollout, Changes: changes})
+}
+
+func (h *AdminHandler) deleteRollout(w http.ResponseWriter, req *http.Request, actor string) {
+	alias := req.PathValue("alias")
+	if err := h.bouncer.db.DeleteRollout(actor, alias); err != nil {
+		h.writeError(w, req, err)
+		return
+	}
+	log.Printf("AdminHandler: %s deleted the rollout of alias %s", actor, alias)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AliasSwitchResponse is the result of a switch. Applied is false for dry
+// runs.
+type AliasSwitchResponse struct {
+	Applied bool          `json:"applied"`
+	Changes []AliasChange `json:"changes"`
+}
+
+func (h *AdminHandler) switchAliases(w http.ResponseWriter, req *http.Request, actor string) {
+	var body AliasSwitch
+	if !h.decode(w, req, &body) {
+		return
+	}
+	for alias := range body.Aliases {
+		if err := validateAdminName("alias", alias); err != nil {
+			h.writeError(w, req, err)
+			return
+		}
+	}
+
+	changes, err := h.bouncer.SwitchAliases(actor, &body)
+	if err != nil {
+		h.writeError(w, req, err)
+		return
+	}
+	if !body.DryRun {
+		log.Printf("AdminHandler: %s switched %d aliases", actor, len(changes))
+	}
+	h.writeJSON(w, false, &AliasSwitchResponse{Applied: !body.DryRun, Changes: changes})
+}
+
+func (h *AdminHandler) scheduledSwitches(w http.ResponseWriter, req *http.Request, _ string) {
+	limit, offset, err := pagination(req.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switches, err := h.bouncer.db.ScheduledAliasSwitches(limit+1, offset)
+	if err != nil {
+		h.writeError(w, req, err)
+		return
+	}
+	h.writeJSON(w, false, newPage(req, switches, limit, offset))
+}
+
+type scheduleSwitchRequest struct {
+	AliasSwitch
+	ActivateAt time.Time `json:"activate_at"`
+}
+
+// ScheduledSwitchResponse is the result of scheduling a switch. Scheduled is
+// empty for dry runs.
+type ScheduledSwitchResponse struct {
+	Scheduled []ScheduledAliasSwitch `json:"scheduled"`
+	Changes   []AliasChange          `json:"changes"`
+}
+
+func (h *AdminHandler) scheduleSwitch(w http.ResponseWriter, req *http.Request, actor string) {
+	var body scheduleSwitchRequest
+	if !h.decode(w, req, &body) {
+		return
+	}
+	for alias := range body.Aliases {
+		if err := validateAdminName("alias", alias); err != nil {
+			h.writeError(w, req, err)
+			return
+		}
+	}
+
+	scheduled, changes, err := h.bouncer.ScheduleAliasSwitch(actor, &body.AliasSwitch, body.ActivateAt)
+	if err != nil {
+		h.writeError(w, req, err)
+		return
+	}
+	if !body.DryRun {
+		log.Printf("AdminHandler: %s scheduled a switch of %d aliases at %s", actor, len(scheduled), body.ActivateAt.UTC().Format(time.RFC3339))
+	}
+	if scheduled == nil {
+		scheduled = []ScheduledAliasSwitch{}
+	}
+	h.writeJSON(w, !body.DryRun, &ScheduledSwitchResponse{Scheduled: scheduled, Changes: changes})
+}
+
+func (h *AdminHandler) previewScheduledSwitch(w http.ResponseWriter, req *http.Request, _ string) {
+	id, err := strconv.ParseInt(req.PathValue("id"), 10, 64)
+	if err != nil {
+		http.NotFound(w, req)
+		return
+	}
+
+	preview, err := h.bouncer.PreviewScheduledAliasSwitch(id)
+	if err != nil {
+		h.writeError(w, req, err)
+		return
+	}
+	h.writeJSON(w, false, preview)
+}
+
+func (h *AdminHandler) cancelScheduledSwitch(w http.ResponseWriter, req *http.Request, actor string) {
+	id, err := strconv.ParseInt(req.PathValue("id"), 10, 64)
+	if err != nil {
+		http.NotFound(w, req)
+		return
+	}
+
+	if err := h.bouncer.db.CancelScheduledAliasSwitch(actor, id); err != nil {
+		h.writeError(w, req, err)
+		return
+	}
+	log.Printf("AdminHandler: %s cancelled scheduled switch %d", actor, id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type putProductRequest struct {
+	SSLOnly bool `json:"ssl_only"`
+}
+
+func (h *AdminHandler) putProduct(w http.ResponseWriter, req *http.Request, actor string) {
+	name := req.PathValue("product")
+	var body putProductRequest
+	if !h.decode(w, req, &body) {
+		return
+	}
+	if err := validateAdminName("product", name); err != nil {
+		h.writeError(w, req, err)
+		return
+	}
+
+	created, err := h.bouncer.db.PutProduct(actor, name, body.SSLOnly)
+	if err != nil {
+		h.writeError(w, req, err)
+		return
+	}
+	log.Printf("AdminHandler: %s set product %s (ssl_only: %t)", actor, name, body.SSLOnly)
+
+	product, err := h.bouncer.db.ProductByName(name)
+	if err != nil {
+		h.writeError(w, req, err)
+		return
+	}
+	h.writeJSON(w, created, product)
+}
+
+func (h *AdminHandler) deleteProduct(w http.ResponseWriter, req *http.Request, actor string) {
+	name := req.PathValue("product")
+	if err := h.bouncer.db.DeleteProduct(actor, name); err != nil {
+		h.writeError(w, req, err)
+		return
+	}
+	log.Printf("AdminHandler: %s deleted product %s", actor, name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AdminHandler) putLanguage(w http.ResponseWriter, req *http.Request, actor string) {
+	product, lang := req.PathValue("product"), req.PathValue("lang")
+	if !adminLangRegexp.MatchString(lang) {
+		h.writeError(w, req, fmt.Errorf("%w: invalid language %q", ErrInvalid, lang))
+		return
+	}
+
+	created, err := h.bouncer.db.PutProductLanguage(actor, product, lang)
+	if err != nil {
+		h.writeError(w, req, err)
+		return
+	}
+	if created {
+		log.Printf("AdminHandler: %s added language %s to product %s", actor, lang, product)
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AdminHandler) deleteLanguage(w http.ResponseWriter, req *http.Request, actor string) {
+	product, lang := req.PathValue("product"), req.PathValue("lang")
+	if err := h.bouncer.db.DeleteProductLanguage(actor, product, lang); err != nil {
+		h.writeError(w, req, err)
+		return
+	}
+	log.Printf("AdminHandler: %s removed language %s from product %s", actor, lang, product)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AdminHandler) locations(w http.ResponseWriter, req *http.Request, _ string) {
+	locations, err := h.bouncer.db.ProductLocationPaths(req.PathValue("product"))
+	if err != nil {
+		h.writeError(w, req, err)
+		return
+	}
+	h.writeJSON(w, false, locations)
+}
+
+type putLocationRequest struct {
+	Path string `json:"path"`
+}
+
+func (h *AdminHandler) putLocation(w http.ResponseWriter, req *http.Request, actor string) {
+	product, os := req.PathValue("product"), req.PathValue("os")
+	var body putLocationRequest
+	if !h.decode(w, req, &body) {
+		return
+	}
+	if err := h.validateLocationPath(body.Path); err != nil {
+		h.writeError(w, req, err)
+		return
+	}
+
+	created, err := h.bouncer.db.PutLocation(actor, product, os, body.Path)
+	if err != nil {
+		h.writeError(w, req, err)
+		return
+	}
+	log.Printf("AdminHandler: %s set location of product %s for %s to %s", actor, product, os, body.Path)
+	h.writeJSON(w, created, &ProductLocationPath{OS: os, Path: body.Path})
+}
+
+func (h *AdminHandler) deleteLocation(w http.ResponseWriter, req *http.Request, actor string) {
+	product, os := req.PathValue("product"), req.PathValue("os")
+	if err := h.bouncer.db.DeleteLocation(actor, product, os); err != nil {
+		h.writeError(w, req, err)
+		return
+	}
+	log.Printf("AdminHandler: %s deleted location of product %s for %s", actor, product, os)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type putExperimentRequest struct {
+	Variant        string    `json:"variant"`
+	StartsAt       time.Time `json:"starts_at"`
+	EndsAt         time.Time `json:"ends_at"`
+	TrafficPercent int       `json:"traffic_percent"`
+}
+
+func (h *AdminHandler) experiments(w http.ResponseWriter, req *http.Request, _ string) {
+	limit, offset, err := pagination(req.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	experiments, err := h.bouncer.db.Experiments(limit+1, offset)
+	if err != nil {
+		h.writeError(w, req, err)
+		return
+	}
+	h.writeJSON(w, false, newPage(req, experiments, limit, offset))
+}
+
+func (h *AdminHandler) experiment(w http.ResponseWriter, req *http.Request, _ string) {
+	e, err := h.bouncer.db.Experiment(req.PathValue("product"), req.PathValue("experiment"))
+	if err != nil {
+		h.writeError(w, req, err)
+		return
+	}
+	h.writeJSON(w, false, e)
+}
+
+func (h *AdminHandler) putExperiment(w http.ResponseWriter, req *http.Request, actor string) {
+	var body putExperimentRequest
+	if !h.decode(w, req, &body) {
+		return
+	}
+	e := &Experiment{
+		Product:        req.PathValue("product"),
+		ID:             req.PathValue("experiment"),
+		Variant:        body.Variant,
+		StartsAt:       body.StartsAt,
+		EndsAt:         body.EndsAt,
+		TrafficPercent: body.TrafficPercent,
+	}
+	if err := validateAdminName("experiment", e.ID); err != nil {
+		h.writeError(w, req, err)
+		return
+	}
+
+	created, err := h.bouncer.db.PutExperiment(actor, e)
+	if err != nil {
+		h.writeError(w, req, err)
+		return
+	}
+	log.Printf("AdminHandler: %s set experiment %s of %s to %s from %s to %s", actor, e.ID, e.Product, e.Variant, e.StartsAt, e.EndsAt)
+	h.writeJSON(w, created, e)
+}
+
+func (h *AdminHandler) deleteExperiment(w http.ResponseWriter, req *http.Request, actor string) {
+	product, id := req.PathValue("product"), req.PathValue("experiment")
+	if err := h.bouncer.db.DeleteExperiment(actor, product, id); err != nil {
+		h.writeError(w, req, err)
+		return
+	}
+	log.Printf("AdminHandler: %s deleted experiment %s of %s", actor, id, product)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AdminHandler) auditLog(w http.ResponseWriter, req *http.Request, _ string) {
+	limit, offset, err := pagination(req.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entries, err := h.bouncer.db.AuditLog(limit+1, offset)
+	if err != nil {
+		h.writeError(w, req, err)
+		return
+	}
+	h.writeJSON(w, false, newPage(req, entries, limit, offset))
+}
+
+// validateLocationPath checks that a location is either a path on the CDN or
+// an absolute URL that bouncer would redirect to.
+func (h *AdminHandler) validateLocationPath(path string) error {
+	if len(path) > maxLocationPathLength {
+		return fmt.Errorf("%w: path must be at most %d characters", ErrInvalid, maxLocationPathLength)
+	}
+	if strings.ContainsFunc(path, func(r rune) bool { return r <= ' ' || r == 0x7f }) {
+		return fmt.Errorf("%w: path must not contain whitespace or control characters", ErrInvalid)
+	}
+	if isAbsoluteLocation(path) {
+		if _, err := h.bouncer.absoluteLocationURL(path); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		return nil
+	}
+	if !strings.HasPrefix(path, "/") {
+		return fmt.Errorf("%w: path must start with / or be an absolute URL", ErrInvalid)
+	}
+	return nil
+}
+
+func validateAdminName(kind, name string) error {
+	if !adminNameRegexp.MatchString(name) {
+		return fmt.Errorf("%w: invalid %s name %q", ErrInvalid, kind, name)
+	}
+	return nil
+}
+
+// decode reads a JSON body into v, rejecting unknown fields. It writes an
+// error and returns false when the body is invalid.
+func (h *AdminHandler) decode(w http.ResponseWriter, req *http.Request, v any) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxAdminRequestBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON body: %v.", err), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// writeError maps errors of the DB methods to statuses.
+func (h *AdminHandler) writeError(w http.ResponseWriter, req *http.Request, err error) {
+	var preflightErr *PreflightError
+	switch {
+	case errors.As(err, &preflightErr):
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(preflightErr)
+	case errors.Is(err, sql.ErrNoRows):
+		http.NotFound(w, req)
+	case errors.Is(err, ErrInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "Internal Server Error.", http.StatusInternalServerError)
+		log.Printf("AdminHandler err: %v", err)
+	}
+}
+
+func (h *AdminHandler) writeJSON(w http.ResponseWriter, created bool, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(v)
+}
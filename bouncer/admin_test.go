@@ -0,0 +1,194 @@
+package bouncer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mozilla-services/go-bouncer/bouncerapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func testAdminToken(name, token string, scopes ...string) AdminToken {
+	sum := sha256.Sum256([]byte(token))
+	return AdminToken{Name: name, TokenSHA256: hex.EncodeToString(sum[:]), Scopes: scopes}
+}
+
+func newTestAdminHandler() *AdminHandler {
+	h := *bouncerHandler
+	h.AbsoluteLocationHosts = []string{"apps.microsoft.com"}
+	return NewAdminHandler(&h, []AdminToken{
+		testAdminToken("releng", "releng-token", AdminScopeAliases, AdminScopeProducts, AdminScopeLocations, AdminScopeExperiments, AdminScopeAudit),
+		testAdminToken("auditor", "auditor-token", AdminScopeAudit),
+	})
+}
+
+func adminRequest(h *AdminHandler, method, path, token, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, "http://test"+path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestAdminHandlerAuth(t *testing.T) {
+	h := newTestAdminHandler()
+
+	w := adminRequest(h, "GET", "/admin/v1/audit", "", "")
+	assert.Equal(t, 401, w.Code)
+	assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+
+	w = adminRequest(h, "GET", "/admin/v1/audit", "wrong-token", "")
+	assert.Equal(t, 401, w.Code)
+
+	w = adminRequest(h, "GET", "/admin/v1/audit", "auditor-token", "")
+	assert.Equal(t, 200, w.Code)
+
+	// The auditor can't change aliases.
+	w = adminRequest(h, "PUT", "/admin/v1/aliases/firefox-latest", "auditor-token", `{"product": "Firefox-SSL"}`)
+	assert.Equal(t, 403, w.Code)
+
+	product, err := testDB.AliasFor("firefox-latest")
+	assert.NoError(t, err)
+	assert.Equal(t, "Firefox", product)
+}
+
+func TestAdminHandlerCatalog(t *testing.T) {
+	h := newTestAdminHandler()
+
+	// Product.
+	w := adminRequest(h, "PUT", "/admin/v1/products/Firefox-admin-test", "releng-token", `{"ssl_only": true}`)
+	assert.Equal(t, 201, w.Code)
+	var product Product
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &product))
+	assert.Equal(t, "Firefox-admin-test", product.Name)
+	assert.True(t, product.SSLOnly)
+
+	w = adminRequest(h, "PUT", "/admin/v1/products/Firefox-admin-test", "releng-token", `{"ssl_only": false}`)
+	assert.Equal(t, 200, w.Code)
+
+	// Languages.
+	w = adminRequest(h, "PUT", "/admin/v1/products/Firefox-admin-test/languages/en-US", "releng-token", "")
+	assert.Equal(t, 201, w.Code)
+	w = adminRequest(h, "PUT", "/admin/v1/products/Firefox-admin-test/languages/en-US", "releng-token", "")
+	assert.Equal(t, 204, w.Code)
+	w = adminRequest(h, "PUT", "/admin/v1/products/Firefox-admin-test/languages/de", "releng-token", "")
+	assert.Equal(t, 201, w.Code)
+	w = adminRequest(h, "DELETE", "/admin/v1/products/Firefox-admin-test/languages/de", "releng-token", "")
+	assert.Equal(t, 204, w.Code)
+	w = adminRequest(h, "DELETE", "/admin/v1/products/Firefox-admin-test/languages/de", "releng-token", "")
+	assert.Equal(t, 404, w.Code)
+
+	// Locations.
+	w = adminRequest(h, "PUT", "/admin/v1/products/Firefox-admin-test/locations/win64", "releng-token", `{"path": "/firefox/releases/140.0/win64/:lang/Firefox%20Setup%20140.0.exe"}`)
+	assert.Equal(t, 201, w.Code)
+	w = adminRequest(h, "PUT", "/admin/v1/products/Firefox-admin-test/locations/osx", "releng-token", `{"path": "/firefox/releases/140.0/mac/:lang/Firefox%20140.0.dmg"}`)
+	assert.Equal(t, 201, w.Code)
+	w = adminRequest(h, "PUT", "/admin/v1/products/Firefox-admin-test/locations/osx", "releng-token", `{"path": "/firefox/releases/140.0.1/mac/:lang/Firefox%20140.0.1.dmg"}`)
+	assert.Equal(t, 200, w.Code)
+
+	w = adminRequest(h, "GET", "/admin/v1/products/Firefox-admin-test/locations", "releng-token", "")
+	assert.Equal(t, 200, w.Code)
+	var locations []ProductLocationPath
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &locations))
+	assert.Equal(t, []ProductLocationPath{
+		{OS: "osx", Path: "/firefox/releases/140.0.1/mac/:lang/Firefox%20140.0.1.dmg"},
+		{OS: "win64", Path: "/firefox/releases/140.0/win64/:lang/Firefox%20Setup%20140.0.exe"},
+	}, locations)
+
+	// Alias.
+	w = adminRequest(h, "PUT", "/admin/v1/aliases/firefox-admin-test-latest", "releng-token", `{"product": "Firefox-admin-test"}`)
+	assert.Equal(t, 201, w.Code)
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://test/?product=firefox-admin-test-latest&os=osx&lang=en-US", nil)
+	bouncerHandler.ServeHTTP(w, req)
+	assert.Equal(t, 302, w.Code)
+	assert.Equal(t, "http://download.cdn.mozilla.net/pub/firefox/releases/140.0.1/mac/en-US/Firefox%20140.0.1.dmg", w.Header().Get("Location"))
+
+	// Products that an alias points to can't be deleted.
+	w = adminRequest(h, "DELETE", "/admin/v1/products/Firefox-admin-test", "releng-token", "")
+	assert.Equal(t, 409, w.Code)
+
+	w = adminRequest(h, "DELETE", "/admin/v1/aliases/firefox-admin-test-latest", "releng-token", "")
+	assert.Equal(t, 204, w.Code)
+	w = adminRequest(h, "DELETE", "/admin/v1/aliases/firefox-admin-test-latest", "releng-token", "")
+	assert.Equal(t, 404, w.Code)
+
+	w = adminRequest(h, "DELETE", "/admin/v1/products/Firefox-admin-test/locations/win64", "releng-token", "")
+	assert.Equal(t, 204, w.Code)
+
+	w = adminRequest(h, "DELETE", "/admin/v1/products/Firefox-admin-test", "releng-token", "")
+	assert.Equal(t, 204, w.Code)
+	w = adminRequest(h, "GET", "/admin/v1/products/Firefox-admin-test/locations", "releng-token", "")
+	assert.Equal(t, 404, w.Code)
+
+	// The audit log has every change, most recent first.
+	w = adminRequest(h, "GET", "/admin/v1/audit?limit=2", "auditor-token", "")
+	assert.Equal(t, 200, w.Code)
+	var page bouncerapi.Page[AuditEntry]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Len(t, page.Items, 2)
+	assert.NotEmpty(t, page.Next)
+
+	entry := page.Items[0]
+	assert.Equal(t, "releng", entry.Actor)
+	assert.Equal(t, AuditActionDelete, entry.Action)
+	assert.Equal(t, AuditResourceProduct, entry.Resource)
+	assert.Equal(t, "Firefox-admin-test", entry.Key)
+	assert.False(t, entry.Time.IsZero())
+	assert.JSONEq(t, `{
+		"product": "Firefox-admin-test",
+		"ssl_only": false,
+		"languages": ["en-US"],
+		"locations": [{"os": "osx", "path": "/firefox/releases/140.0.1/mac/:lang/Firefox%20140.0.1.dmg"}]
+	}`, string(entry.Old))
+	assert.JSONEq(t, "null", string(entry.New))
+
+	entry = page.Items[1]
+	assert.Equal(t, AuditResourceLocation, entry.Resource)
+	assert.Equal(t, "Firefox-admin-test/win64", entry.Key)
+}
+
+func TestAdminHandlerValidation(t *testing.T) {
+	h := newTestAdminHandler()
+
+	for _, tc := range []struct {
+		method, path, body string
+		code               int
+	}{
+		{"PUT", "/admin/v1/aliases/firefox-latest", `{"product": "unknown-product"}`, 400},
+		{"PUT", "/admin/v1/aliases/firefox-latest", `{"product": "Firefox", "typo": 1}`, 400},
+		{"PUT", "/admin/v1/aliases/firefox-latest", `{`, 400},
+		{"PUT", "/admin/v1/aliases/bad%20alias", `{"product": "Firefox"}`, 400},
+		{"PUT", "/admin/v1/aliases/Firefox-SSL", `{"product": "Firefox"}`, 409},
+		{"PUT", "/admin/v1/products/-bad", `{}`, 400},
+		{"PUT", "/admin/v1/products/firefox-latest", `{}`, 409},
+		{"PUT", "/admin/v1/products/Firefox/languages/not_a_lang", "", 400},
+		{"PUT", "/admin/v1/products/unknown-product/languages/en-US", "", 404},
+		{"PUT", "/admin/v1/products/Firefox/locations/typo", `{"path": "/firefox/x.exe"}`, 400},
+		{"PUT", "/admin/v1/products/Firefox/locations/win64", `{"path": "firefox/x.exe"}`, 400},
+		{"PUT", "/admin/v1/products/Firefox/locations/win64", `{"path": "/firefox/a b.exe"}`, 400},
+		{"PUT", "/admin/v1/products/Firefox/locations/win64", `{"path": "https://evil.example.com/x.exe"}`, 400},
+		{"PUT", "/admin/v1/products/Firefox/locations/win64", `{"path": "http://apps.microsoft.com/x"}`, 400},
+		{"PUT", "/admin/v1/products/unknown-product/locations/win64", `{"path": "/firefox/x.exe"}`, 404},
+		{"DELETE", "/admin/v1/products/unknown-product", "", 404},
+		{"GET", "/admin/v1/audit?limit=0", "", 400},
+	} {
+		w := adminRequest(h, tc.method, tc.path, "releng-token", tc.body)
+		assert.Equal(t, tc.code, w.Code, "%s %s %s", tc.method, tc.path, tc.body)
+	}
+
+	// Nothing changed.
+	product, err := testDB.AliasFor("firefox-latest")
+	assert.NoError(t, err)
+	assert.Equal(t, "Firefox", product)
+	_, path, err := testDB.Location("1", "1")
+	assert.NoError(t, err)
+	assert.Equal(t, "/firefox/releases/39.0/win64/:lang/Firefox%20Setup%2039.0.exe", path)
+}
@@ -0,0 +1,539 @@
+package bouncer
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Actions recorded in the audit log.
+const (
+	AuditActionPut    = "put"
+	AuditActionDelete = "delete"
+)
+
+// Resources recorded in the audit log.
+const (
+	AuditResourceAlias    = "alias"
+	AuditResourceProduct  = "product"
+	AuditResourceLanguage = "language"
+	AuditResourceLocation = "location"
+	// AuditResourceScheduledSwitch is a scheduled alias switch, keyed by ID.
+	AuditResourceScheduledSwitch = "scheduled_switch"
+	AuditResourceRollout         = "rollout"
+	// AuditResourceExperiment is an experiment, keyed by base product and
+	// experiment ID, e.g. firefox-stub/137.
+	AuditResourceExperiment = "experiment"
+)
+
+var (
+	// ErrInvalid is wrapped by the errors returned for changes with invalid
+	// input, e.g. an alias pointing to an unknown product.
+	ErrInvalid = errors.New("invalid")
+	// ErrConflict is wrapped by the errors returned for changes that would
+	// break the catalog, e.g. deleting a product that an alias points to.
+	ErrConflict = errors.New("conflict")
+)
+
+// auditTimeLayouts are the layouts of DATETIME columns, depending on whether
+// the DSN sets parseTime.
+var auditTimeLayouts = []string{"2006-01-02 15:04:05.999999", time.RFC3339Nano}
+
+// AuditEntry is a change made through the admin API. Old and New are the
+// state of the resource before and after the change, null when it didn't
+// exist.
+type AuditEntry struct {
+	ID       int64           `json:"id"`
+	Time     time.Time       `json:"time"`
+	Actor    string          `json:"actor"`
+	Action   string          `json:"action"`
+	Resource string          `json:"resource"`
+	Key      string          `json:"key"`
+	Old      json.RawMessage `json:"old"`
+	New      json.RawMessage `json:"new"`
+}
+
+// AuditLog returns a page of the audit log, most recent first.
+func (d *DB) AuditLog(limit, offset int) ([]AuditEntry, error) {
+	rows, err := d.Query(
+		`SELECT id, created_at, actor, action, resource, resource_key, old_value, new_value
+			FROM mirror_admin_audit_log
+			ORDER BY id DESC
+			LIMIT ? OFFSET ?`,
+		limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var entry AuditEntry
+		var createdAt string
+		var oldValue, newValue sql.NullString
+		if err := rows.Scan(&entry.ID, &createdAt, &entry.Actor, &entry.Action, &entry.Resource, &entry.Key, &oldValue, &newValue); err != nil {
+			return nil, err
+		}
+		if entry.Time, err = parseDBTime(createdAt); err != nil {
+			return nil, err
+		}
+		if oldValue.Valid {
+			entry.Old = json.RawMessage(oldValue.String)
+		}
+		if newValue.Valid {
+			entry.New = json.RawMessage(newValue.String)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// ProductLocationPath is the path of a product for an operating system, by
+// name.
+type ProductLocationPath struct {
+	OS   string `json:"os"`
+	Path string `json:"path"`
+}
+
+// ProductLocationPaths returns the locations of a product, by name.
+// sql.ErrNoRows is returned when the product doesn't exist.
+func (d *DB) ProductLocationPaths(product string) ([]ProductLocationPath, error) {
+	productID, _, err := productRow(d.DB, product)
+	if err != nil {
+		return nil, err
+	}
+	return locationPaths(d.DB, productID)
+}
+
+// PutAlias points an alias to a product, creating the alias if needed. An
+// alias cannot have the name of a product.
+func (d *DB) PutAlias(actor, alias, product string) (created bool, err error) {
+	err = d.inTx(func(tx *sql.Tx) error {
+		if _, _, err := productRow(tx, product); errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: unknown product %s", ErrInvalid, product)
+		} else if err != nil {
+			return err
+		}
+		if err := checkAliasName(tx, alias); err != nil {
+			return err
+		}
+
+		oldProduct, err := currentAlias(tx, alias)
+		if err != nil {
+			return err
+		}
+		created = oldProduct == ""
+		return setAlias(tx, actor, alias, oldProduct, product, time.Now().UTC())
+	})
+	return created, err
+}
+
+// DeleteAlias deletes an alias. sql.ErrNoRows is returned when it doesn't
+// exist.
+func (d *DB) DeleteAlias(actor, alias string) error {
+	return d.inTx(func(tx *sql.Tx) error {
+		product, err := currentAlias(tx, alias)
+		if err != nil {
+			return err
+		}
+		if product == "" {
+			return sql.ErrNoRows
+		}
+		if targets, err := rolloutTargets(tx, alias); err != nil {
+			return err
+		} else if len(targets) > 0 {
+			return fmt.Errorf("%w: alias %s has a rollout", ErrConflict, alias)
+		}
+		if e, err := experimentUsing(tx, alias); err == nil {
+			return fmt.Errorf("%w: experiment %s of alias %s hasn't ended", ErrConflict, e.ID, alias)
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		return setAlias(tx, actor, alias, product, "", time.Now().UTC())
+	})
+}
+
+// PutProduct creates or updates a product. A product cannot have the name of
+// an alias.
+func (d *DB) PutProduct(actor, name string, sslOnly bool) (created bool, err error) {
+	err = d.inTx(func(tx *sql.Tx) error {
+		var old any
+		id, oldSSLOnly, err := productRow(tx, name)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			var alias string
+			if alias, err = currentAlias(tx, name); err != nil {
+				return err
+			}
+			if alias != "" {
+				return fmt.Errorf("%w: %s is an alias", ErrConflict, name)
+			}
+			var switchID int64
+			err = tx.QueryRow(
+				"SELECT id FROM mirror_alias_schedule WHERE alias = ? AND promoted_at IS NULL LIMIT 1",
+				name).Scan(&switchID)
+			if err == nil {
+				return fmt.Errorf("%w: scheduled switch %d creates alias %s", ErrConflict, switchID, name)
+			}
+			if !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+			created = true
+			_, err = tx.Exec(
+				"INSERT INTO mirror_products (name, ssl_only) VALUES (?, ?)",
+				name, boolInt(sslOnly))
+		case err == nil:
+			old = productAuditValue(name, oldSSLOnly)
+			_, err = tx.Exec(
+				"UPDATE mirror_products SET ssl_only = ? WHERE id = ?",
+				boolInt(sslOnly), id)
+		}
+		if err != nil {
+			return err
+		}
+		return audit(tx, actor, AuditActionPut, AuditResourceProduct, name, old, productAuditValue(name, sslOnly))
+	})
+	return created, err
+}
+
+// DeleteProduct deletes a product along with its languages and locations.
+// Products that an alias points to cannot be deleted. sql.ErrNoRows is
+// returned when the product doesn't exist.
+func (d *DB) DeleteProduct(actor, name string) error {
+	return d.inTx(func(tx *sql.Tx) error {
+		id, sslOnly, err := productRow(tx, name)
+		if err != nil {
+			return err
+		}
+
+		var alias string
+		err = tx.QueryRow(
+			"SELECT alias FROM mirror_aliases WHERE related_product = ? LIMIT 1",
+			name).Scan(&alias)
+		if err == nil {
+			return fmt.Errorf("%w: alias %s points to product %s", ErrConflict, alias, name)
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		var switchID int64
+		err = tx.QueryRow(
+			"SELECT id FROM mirror_alias_schedule WHERE product = ? AND promoted_at IS NULL LIMIT 1",
+			name).Scan(&switchID)
+		if err == nil {
+			return fmt.Errorf("%w: scheduled switch %d points to product %s", ErrConflict, switchID, name)
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		err = tx.QueryRow(
+			"SELECT alias FROM mirror_alias_rollouts WHERE product = ? LIMIT 1",
+			name).Scan(&alias)
+		if err == nil {
+			return fmt.Errorf("%w: the rollout of alias %s targets product %s", ErrConflict, alias, name)
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if e, err := experimentUsing(tx, name); err == nil {
+			return fmt.Errorf("%w: experiment %s of %s uses product %s and hasn't ended", ErrConflict, e.ID, e.Product, name)
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		// Keep the languages and locations in the audit log, so that the
+		// product can be recreated.
+		old := productAuditValue(name, sslOnly)
+		if old["languages"], err = productLanguages(tx, id); err != nil {
+			return err
+		}
+		if old["locations"], err = locationPaths(tx, id); err != nil {
+			return err
+		}
+
+		for _, query := range []string{
+			`DELETE FROM mirror_location_metadata WHERE location_id IN (
+				SELECT id FROM mirror_locations WHERE product_id = ?)`,
+			"DELETE FROM mirror_locations WHERE product_id = ?",
+			"DELETE FROM mirror_product_langs WHERE product_id = ?",
+			"DELETE FROM mirror_products WHERE id = ?",
+		} {
+			if _, err := tx.Exec(query, id); err != nil {
+				return err
+			}
+		}
+		return audit(tx, actor, AuditActionDelete, AuditResourceProduct, name, old, nil)
+	})
+}
+
+// PutProductLanguage makes a product available in a language.
+// sql.ErrNoRows is returned when the product doesn't exist.
+func (d *DB) PutProductLanguage(actor, product, lang string) (created bool, err error) {
+	err = d.inTx(func(tx *sql.Tx) error {
+		id, _, err := productRow(tx, product)
+		if err != nil {
+			return err
+		}
+
+		var langID string
+		err = tx.QueryRow(
+			"SELECT id FROM mirror_product_langs WHERE product_id = ? AND language = ?",
+			id, lang).Scan(&langID)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		created = true
+		if _, err := tx.Exec(
+			"INSERT INTO mirror_product_langs (product_id, language) VALUES (?, ?)",
+			id, lang); err != nil {
+			return err
+		}
+		return audit(tx, actor, AuditActionPut, AuditResourceLanguage, product+"/"+lang, nil, languageAuditValue(product, lang))
+	})
+	return created, err
+}
+
+// DeleteProductLanguage removes a language from a product. sql.ErrNoRows is
+// returned when the product isn't available in the language.
+func (d *DB) DeleteProductLanguage(actor, product, lang string) error {
+	return d.inTx(func(tx *sql.Tx) error {
+		id, _, err := productRow(tx, product)
+		if err != nil {
+			return err
+		}
+
+		result, err := tx.Exec(
+			"DELETE FROM mirror_product_langs WHERE product_id = ? AND language = ?",
+			id, lang)
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return sql.ErrNoRows
+		}
+		return audit(tx, actor, AuditActionDelete, AuditResourceLanguage, product+"/"+lang, languageAuditValue(product, lang), nil)
+	})
+}
+
+// PutLocation sets the path of a product for an operating system.
+// sql.ErrNoRows is returned when the product doesn't exist.
+func (d *DB) PutLocation(actor, product, os, path string) (created bool, err error) {
+	err = d.inTx(func(tx *sql.Tx) error {
+		productID, _, err := productRow(tx, product)
+		if err != nil {
+			return err
+		}
+
+		var osID string
+		err = tx.QueryRow("SELECT id FROM mirror_os WHERE name = ?", os).Scan(&osID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: unknown os %s", ErrInvalid, os)
+		}
+		if err != nil {
+			return err
+		}
+
+		var old any
+		var id, oldPath string
+		err = tx.QueryRow(
+			"SELECT id, path FROM mirror_locations WHERE product_id = ? AND os_id = ?",
+			productID, osID).Scan(&id, &oldPath)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			created = true
+			_, err = tx.Exec(
+				"INSERT INTO mirror_locations (product_id, os_id, path) VALUES (?, ?, ?)",
+				productID, osID, path)
+		case err == nil:
+			old = locationAuditValue(product, os, oldPath)
+			_, err = tx.Exec(
+				"UPDATE mirror_locations SET path = ? WHERE id = ?",
+				path, id)
+		}
+		if err != nil {
+			return err
+		}
+		return audit(tx, actor, AuditActionPut, AuditResourceLocation, product+"/"+os, old, locationAuditValue(product, os, path))
+	})
+	return created, err
+}
+
+// DeleteLocation deletes the location of a product for an operating system,
+// along with its metadata. sql.ErrNoRows is returned when it doesn't exist.
+func (d *DB) DeleteLocation(actor, product, os string) error {
+	return d.inTx(func(tx *sql.Tx) error {
+		productID, _, err := productRow(tx, product)
+		if err != nil {
+			return err
+		}
+
+		var id, path string
+		err = tx.QueryRow(
+			`SELECT loc.id, loc.path FROM mirror_locations AS loc
+				JOIN mirror_os AS os ON (loc.os_id = os.id)
+				WHERE loc.product_id = ? AND os.name = ?`,
+			productID, os).Scan(&id, &path)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec("DELETE FROM mirror_location_metadata WHERE location_id = ?", id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM mirror_locations WHERE id = ?", id); err != nil {
+			return err
+		}
+		return audit(tx, actor, AuditActionDelete, AuditResourceLocation, product+"/"+os, locationAuditValue(product, os, path), nil)
+	})
+}
+
+// inTx runs fn in a transaction, which is committed when fn succeeds.
+func (d *DB) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := d.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// queryer is implemented by *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryRow(query string, args ...any) *sql.Row
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// audit appends an entry to the audit log. The log is never updated, so that
+// it can be trusted.
+func audit(tx *sql.Tx, actor, action, resource, key string, old, new any) error {
+	oldValue, err := auditValue(old)
+	if err != nil {
+		return err
+	}
+	newValue, err := auditValue(new)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO mirror_admin_audit_log (created_at, actor, action, resource, resource_key, old_value, new_value)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		time.Now().UTC(), actor, action, resource, key, oldValue, newValue)
+	return err
+}
+
+func auditValue(v any) (sql.NullString, error) {
+	if v == nil {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+func productAuditValue(name string, sslOnly bool) map[string]any {
+	return map[string]any{"product": name, "ssl_only": sslOnly}
+}
+
+func languageAuditValue(product, lang string) map[string]any {
+	return map[string]any{"product": product, "language": lang}
+}
+
+func locationAuditValue(product, os, path string) map[string]any {
+	return map[string]any{"product": product, "os": os, "path": path}
+}
+
+// productRow returns the id of a product, by name, and whether it is
+// ssl-only.
+// checkAliasName returns an ErrConflict when an alias has the name of a
+// product, since aliases would shadow it.
+func checkAliasName(q queryer, alias string) error {
+	_, _, err := productRow(q, alias)
+	if err == nil {
+		return fmt.Errorf("%w: %s is a product", ErrConflict, alias)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	return err
+}
+
+func productRow(q queryer, name string) (id string, sslOnly bool, err error) {
+	sslInt := 0
+	err = q.QueryRow(
+		"SELECT id, ssl_only FROM mirror_products WHERE name = ?",
+		name).Scan(&id, &sslInt)
+	return id, sslInt == 1, err
+}
+
+func productLanguages(q queryer, productID string) ([]string, error) {
+	rows, err := q.Query(
+		"SELECT language FROM mirror_product_langs WHERE product_id = ? ORDER BY language",
+		productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	languages := []string{}
+	for rows.Next() {
+		var language string
+		if err := rows.Scan(&language); err != nil {
+			return nil, err
+		}
+		languages = append(languages, language)
+	}
+	return languages, rows.Err()
+}
+
+func locationPaths(q queryer, productID string) ([]ProductLocationPath, error) {
+	rows, err := q.Query(
+		`SELECT os.name, loc.path FROM mirror_locations AS loc
+			JOIN mirror_os AS os ON (loc.os_id = os.id)
+			WHERE loc.product_id = ?
+			ORDER BY os.name`,
+		productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	locations := []ProductLocationPath{}
+	for rows.Next() {
+		var location ProductLocationPath
+		if err := rows.Scan(&location.OS, &location.Path); err != nil {
+			return nil, err
+		}
+		locations = append(locations, location)
+	}
+	return locations, rows.Err()
+}
+
+func parseDBTime(value string) (time.Time, error) {
+	for _, layout := range auditTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", value)
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
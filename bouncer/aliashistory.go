@@ -0,0 +1,192 @@
+package bouncer
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// AliasHistoryEntry is a change of the product an alias points to.
+// OldProduct is empty when the alias was created, and NewProduct when it was
+// deleted.
+type AliasHistoryEntry struct {
+	Alias      string    `json:"alias"`
+	OldProduct string    `json:"old_product,omitempty"`
+	NewProduct string    `json:"new_product,omitempty"`
+	Time       time.Time `json:"time"`
+	Actor      string    `json:"actor"`
+}
+
+// AliasHistory returns a page of the changes of an alias, most recent first.
+func (d *DB) AliasHistory(alias string, limit, offset int) ([]AliasHistoryEntry, error) {
+	rows, err := d.Query(
+		`SELECT alias, old_product, new_product, changed_at, actor FROM mirror_alias_history
+			WHERE alias = ?
+			ORDER BY changed_at DESC, id DESC
+			LIMIT ? OFFSET ?`,
+		alias, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []AliasHistoryEntry{}
+	for rows.Next() {
+		var entry AliasHistoryEntry
+		var oldProduct, newProduct sql.NullString
+		var changedAt string
+		if err := rows.Scan(&entry.Alias, &oldProduct, &newProduct, &changedAt, &entry.Actor); err != nil {
+			return nil, err
+		}
+		if entry.Time, err = parseDBTime(changedAt); err != nil {
+			return nil, err
+		}
+		entry.OldProduct, entry.NewProduct = oldProduct.String, newProduct.String
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// AliasAt is like AliasFor, for the product that an alias pointed to at a
+// given time, or will point to given the scheduled switches. Aliases without
+// history are resolved like AliasFor.
+func (d *DB) AliasAt(product string, t time.Time) (string, error) {
+	// Switches that are due but not promoted yet are more recent than the
+	// history. This also resolves scheduled switches when t is in the future.
+	var scheduled string
+	err := d.QueryRow(
+		`SELECT product FROM mirror_alias_schedule
+			WHERE alias = ? AND promoted_at IS NULL AND activate_at <= ?
+			ORDER BY activate_at DESC, id DESC
+			LIMIT 1`,
+		product, t.UTC()).Scan(&scheduled)
+	if err == nil {
+		return scheduled, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	// The last change before t has the product the alias pointed to then.
+	var related sql.NullString
+	err = d.QueryRow(
+		`SELECT new_product FROM mirror_alias_history
+			WHERE alias = ? AND changed_at <= ?
+			ORDER BY changed_at DESC, id DESC
+			LIMIT 1`,
+		product, t.UTC()).Scan(&related)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Otherwise, the first change after t has it.
+		err = d.QueryRow(
+			`SELECT old_product FROM mirror_alias_history
+				WHERE alias = ? AND changed_at > ?
+				ORDER BY changed_at, id
+				LIMIT 1`,
+			product, t.UTC()).Scan(&related)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		related.String, err = aliasProductAt(d, product, t)
+		related.Valid = related.String != ""
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// The alias didn't exist.
+	if !related.Valid {
+		return product, nil
+	}
+	return related.String, nil
+}
+
+// RollbackAlias points an alias back to the product it pointed to before its
+// last change, and returns it. Rolling back twice undoes the rollback.
+// sql.ErrNoRows is returned when the alias has no history.
+func (d *DB) RollbackAlias(actor, alias string) (product string, err error) {
+	err = d.inTx(func(tx *sql.Tx) error {
+		// Due switches are promoted first, so that they can be rolled back.
+		actual, err := currentAlias(tx, alias)
+		if err != nil {
+			return err
+		}
+
+		var previous, current sql.NullString
+		err = tx.QueryRow(
+			`SELECT old_product, new_product FROM mirror_alias_history
+				WHERE alias = ?
+				ORDER BY changed_at DESC, id DESC
+				LIMIT 1`,
+			alias).Scan(&previous, &current)
+		if err != nil {
+			return err
+		}
+		if !previous.Valid {
+			return fmt.Errorf("%w: alias %s has no previous product", ErrConflict, alias)
+		}
+
+		// The alias may have been changed outside of bouncer since.
+		if actual != current.String {
+			return fmt.Errorf("%w: alias %s was changed outside of the admin API", ErrConflict, alias)
+		}
+
+		if _, _, err := productRow(tx, previous.String); errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: previous product %s no longer exists", ErrConflict, previous.String)
+		} else if err != nil {
+			return err
+		}
+
+		product = previous.String
+		return setAlias(tx, actor, alias, actual, product, time.Now().UTC())
+	})
+	return product, err
+}
+
+// setAlias changes the product an alias points to from oldProduct to
+// newProduct, and records the change in the audit log and the alias history.
+// An empty oldProduct creates the alias, and an empty newProduct deletes it.
+// Unchanged aliases are audited but not added to the history, where changes
+// are recorded at the given time.
+func setAlias(tx *sql.Tx, actor, alias, oldProduct, newProduct string, at time.Time) error {
+	var err error
+	switch {
+	case oldProduct == "":
+		_, err = tx.Exec(
+			"INSERT INTO mirror_aliases (alias, related_product) VALUES (?, ?)",
+			alias, newProduct)
+	case newProduct == "":
+		_, err = tx.Exec("DELETE FROM mirror_aliases WHERE alias = ?", alias)
+	default:
+		_, err = tx.Exec(
+			"UPDATE mirror_aliases SET related_product = ? WHERE alias = ?",
+			newProduct, alias)
+	}
+	if err != nil {
+		return err
+	}
+
+	var old, new any
+	if oldProduct != "" {
+		old = &Alias{Alias: alias, Product: oldProduct}
+	}
+	if newProduct != "" {
+		new = &Alias{Alias: alias, Product: newProduct}
+	}
+	action := AuditActionPut
+	if newProduct == "" {
+		action = AuditActionDelete
+	}
+	if err := audit(tx, actor, action, AuditResourceAlias, alias, old, new); err != nil {
+		return err
+	}
+
+	if oldProduct == newProduct {
+		return nil
+	}
+	_, err = tx.Exec(
+		`INSERT INTO mirror_alias_history (alias, old_product, new_product, changed_at, actor)
+			VALUES (?, ?, ?, ?, ?)`,
+		alias, sql.NullString{String: oldProduct, Valid: oldProduct != ""},
+		sql.NullString{String: newProduct, Valid: newProduct != ""}, at, actor)
+	return err
+}
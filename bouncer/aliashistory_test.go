@@ -0,0 +1,118 @@
+package bouncer
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mozilla-services/go-bouncer/bouncerapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAliasAt(t *testing.T) {
+	for _, tc := range []struct {
+		alias    string
+		at       string
+		expected string
+	}{
+		// Before the alias was created.
+		{"firefox-beta-latest", "2024-04-01T00:00:00Z", "firefox-beta-latest"},
+		{"firefox-beta-latest", "2024-05-01T12:00:00Z", "Firefox-127.0b9"},
+		{"firefox-beta-latest", "2024-05-31T23:59:59Z", "Firefox-127.0b9"},
+		{"firefox-beta-latest", "2024-06-01T12:00:00Z", "Firefox"},
+		{"firefox-beta-latest", "2030-01-01T00:00:00Z", "Firefox"},
+		// Without history.
+		{"firefox-latest", "2024-05-15T00:00:00Z", "Firefox"},
+		{"Firefox", "2024-05-15T00:00:00Z", "Firefox"},
+	} {
+		at, err := time.Parse(time.RFC3339, tc.at)
+		assert.NoError(t, err)
+
+		product, err := testDB.AliasAt(tc.alias, at)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, product, "%s at %s", tc.alias, tc.at)
+	}
+}
+
+func TestAdminHandlerAliasHistory(t *testing.T) {
+	h := newTestAdminHandler()
+
+	w := adminRequest(h, "GET", "/admin/v1/aliases/firefox-beta-latest/history?limit=1", "releng-token", "")
+	assert.Equal(t, 200, w.Code)
+
+	var page bouncerapi.Page[AliasHistoryEntry]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Equal(t, []AliasHistoryEntry{
+		{
+			Alias:      "firefox-beta-latest",
+			OldProduct: "Firefox-127.0b9",
+			NewProduct: "Firefox",
+			Time:       time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+			Actor:      "releng",
+		},
+	}, page.Items)
+	assert.Equal(t, "/admin/v1/aliases/firefox-beta-latest/history?limit=1&offset=1", page.Next)
+
+	w = adminRequest(h, "GET", "/admin/v1/aliases/firefox-latest/history", "releng-token", "")
+	assert.Equal(t, 200, w.Code)
+	assert.JSONEq(t, `{"items": [], "limit": 100, "offset": 0}`, w.Body.String())
+
+	w = adminRequest(h, "GET", "/admin/v1/aliases/firefox-beta-latest/history", "auditor-token", "")
+	assert.Equal(t, 403, w.Code)
+}
+
+func TestAdminHandlerAliasRollback(t *testing.T) {
+	t.Cleanup(func() { deleteScheduleTestAlias(t, "firefox-history-test") })
+	h := newTestAdminHandler()
+
+	w := adminRequest(h, "PUT", "/admin/v1/aliases/firefox-history-test", "releng-token", `{"product": "Firefox"}`)
+	assert.Equal(t, 201, w.Code)
+
+	// A new alias has nothing to roll back to.
+	w = adminRequest(h, "POST", "/admin/v1/aliases/firefox-history-test/rollback", "releng-token", "")
+	assert.Equal(t, 409, w.Code)
+
+	w = adminRequest(h, "PUT", "/admin/v1/aliases/firefox-history-test", "releng-token", `{"product": "Firefox-127.0"}`)
+	assert.Equal(t, 200, w.Code)
+	// Unchanged aliases are not in the history.
+	w = adminRequest(h, "PUT", "/admin/v1/aliases/firefox-history-test", "releng-token", `{"product": "Firefox-127.0"}`)
+	assert.Equal(t, 200, w.Code)
+
+	w = adminRequest(h, "POST", "/admin/v1/aliases/firefox-history-test/rollback", "releng-token", "")
+	assert.Equal(t, 200, w.Code)
+	assert.JSONEq(t, `{"alias": "firefox-history-test", "product": "Firefox"}`, w.Body.String())
+
+	product, err := testDB.AliasFor("firefox-history-test")
+	assert.NoError(t, err)
+	assert.Equal(t, "Firefox", product)
+
+	// Rolling back a rollback undoes it.
+	w = adminRequest(h, "POST", "/admin/v1/aliases/firefox-history-test/rollback", "releng-token", "")
+	assert.Equal(t, 200, w.Code)
+	assert.JSONEq(t, `{"alias": "firefox-history-test", "product": "Firefox-127.0"}`, w.Body.String())
+
+	entries, err := testDB.AliasHistory("firefox-history-test", 10, 0)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 4)
+	assert.Equal(t, "Firefox", entries[0].OldProduct)
+	assert.Equal(t, "Firefox-127.0", entries[0].NewProduct)
+	assert.Equal(t, "releng", entries[0].Actor)
+	assert.Empty(t, entries[3].OldProduct)
+
+	// Deleted aliases can be restored.
+	assert.NoError(t, testDB.DeleteAlias("test", "firefox-history-test"))
+	w = adminRequest(h, "POST", "/admin/v1/aliases/firefox-history-test/rollback", "releng-token", "")
+	assert.Equal(t, 200, w.Code)
+	assert.JSONEq(t, `{"alias": "firefox-history-test", "product": "Firefox-127.0"}`, w.Body.String())
+
+	// Changes made outside of the admin API are not rolled back over.
+	_, err = testDB.Exec("UPDATE mirror_aliases SET related_product = 'Firefox-SSL' WHERE alias = 'firefox-history-test'")
+	assert.NoError(t, err)
+	w = adminRequest(h, "POST", "/admin/v1/aliases/firefox-history-test/rollback", "releng-token", "")
+	assert.Equal(t, 409, w.Code)
+
+	w = adminRequest(h, "POST", "/admin/v1/aliases/unknown-alias/rollback", "releng-token", "")
+	assert.Equal(t, 404, w.Code)
+	w = adminRequest(h, "POST", "/admin/v1/aliases/firefox-history-test/rollback", "auditor-token", "")
+	assert.Equal(t, 403, w.Code)
+}
@@ -0,0 +1,402 @@
+package bouncer
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ScheduledAliasSwitch is the change of the product an alias points to at a
+// given time. Aliases point to their new product as soon as the switch is
+// due, and the switch is promoted to mirror_aliases and the alias history
+// afterwards.
+type ScheduledAliasSwitch struct {
+	ID         int64     `json:"id"`
+	Alias      string    `json:"alias"`
+	Product    string    `json:"product"`
+	ActivateAt time.Time `json:"activate_at"`
+	CreatedAt  time.Time `json:"created_at"`
+	Actor      string    `json:"actor"`
+}
+
+// ScheduledAliasSwitchPreview is a scheduled switch along with its change
+// and the problems that the preflight check of a switch would report, as of
+// now. The change is relative to the product the alias will point to right
+// before the switch.
+type ScheduledAliasSwitchPreview struct {
+	*ScheduledAliasSwitch
+	Change   *AliasChange `json:"change,omitempty"`
+	Problems []string     `json:"problems"`
+}
+
+// ScheduledAliasSwitches returns a page of the switches that haven't been
+// promoted yet, in the order they activate.
+func (d *DB) ScheduledAliasSwitches(limit, offset int) ([]ScheduledAliasSwitch, error) {
+	rows, err := d.Query(
+		`SELECT id, alias, product, activate_at, created_at, actor FROM mirror_alias_schedule
+			WHERE promoted_at IS NULL
+			ORDER BY activate_at, id
+			LIMIT ? OFFSET ?`,
+		limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	switches := []ScheduledAliasSwitch{}
+	for rows.Next() {
+		s, err := scanScheduledAliasSwitch(rows)
+		if err != nil {
+			return nil, err
+		}
+		switches = append(switches, *s)
+	}
+	return switches, rows.Err()
+}
+
+// ScheduledAliasSwitch returns a switch that hasn't been promoted yet, or
+// sql.ErrNoRows.
+func (d *DB) ScheduledAliasSwitch(id int64) (*ScheduledAliasSwitch, error) {
+	return scheduledAliasSwitch(d, id)
+}
+
+// CancelScheduledAliasSwitch deletes a switch that isn't due yet. Due
+// switches are live, and are undone by a rollback of their alias instead.
+func (d *DB) CancelScheduledAliasSwitch(actor string, id int64) error {
+	return d.inTx(func(tx *sql.Tx) error {
+		s, err := scheduledAliasSwitch(tx, id)
+		if err != nil {
+			return err
+		}
+		if !s.ActivateAt.After(time.Now()) {
+			return fmt.Errorf("%w: switch %d is already active", ErrConflict, id)
+		}
+
+		if _, err := tx.Exec("DELETE FROM mirror_alias_schedule WHERE id = ?", id); err != nil {
+			return err
+		}
+		return audit(tx, actor, AuditActionDelete, AuditResourceScheduledSwitch, strconv.FormatInt(id, 10), s, nil)
+	})
+}
+
+// ScheduleAliasSwitch schedules a switch of many aliases at the same time,
+// after running its preflight check against the products that the aliases
+// will point to then. Dry runs return the changes without scheduling them.
+func (r *Resolver) ScheduleAliasSwitch(actor string, s *AliasSwitch, at time.Time) ([]ScheduledAliasSwitch, []AliasChange, error) {
+	aliases, err := s.sortedAliases()
+	if err != nil {
+		return nil, nil, err
+	}
+	// The database stores microseconds.
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	at = at.UTC().Truncate(time.Microsecond)
+	if !at.After(now) {
+		return nil, nil, fmt.Errorf("%w: switches must be scheduled in the future", ErrInvalid)
+	}
+
+	var scheduled []ScheduledAliasSwitch
+	var changes []AliasChange
+	err = r.db.inTx(func(tx *sql.Tx) error {
+		var problems []string
+		changes, problems, err = r.preflightAliasSwitch(tx, s, aliases, at)
+		if err != nil {
+			return err
+		}
+		if len(problems) > 0 {
+			return &PreflightError{Problems: problems}
+		}
+		if s.DryRun {
+			return nil
+		}
+
+		scheduled = make([]ScheduledAliasSwitch, 0, len(aliases))
+		for _, alias := range aliases {
+			var id int64
+			err := tx.QueryRow(
+				`SELECT id FROM mirror_alias_schedule
+					WHERE alias = ? AND activate_at = ? AND promoted_at IS NULL`,
+				alias, at).Scan(&id)
+			if err == nil {
+				return fmt.Errorf("%w: switch %d of alias %s is scheduled at the same time", ErrConflict, id, alias)
+			}
+			if !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+
+			sw := ScheduledAliasSwitch{Alias: alias, Product: s.Aliases[alias], ActivateAt: at, CreatedAt: now, Actor: actor}
+			result, err := tx.Exec(
+				`INSERT INTO mirror_alias_schedule (alias, product, activate_at, created_at, actor)
+					VALUES (?, ?, ?, ?, ?)`,
+				sw.Alias, sw.Product, sw.ActivateAt, sw.CreatedAt, sw.Actor)
+			if err != nil {
+				return err
+			}
+			if sw.ID, err = result.LastInsertId(); err != nil {
+				return err
+			}
+			if err := audit(tx, actor, AuditActionPut, AuditResourceScheduledSwitch, strconv.FormatInt(sw.ID, 10), nil, &sw); err != nil {
+				return err
+			}
+			scheduled = append(scheduled, sw)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return scheduled, changes, nil
+}
+
+// PreviewScheduledAliasSwitch returns a switch that hasn't been promoted yet
+// along with its change, or sql.ErrNoRows.
+func (r *Resolver) PreviewScheduledAliasSwitch(id int64) (*ScheduledAliasSwitchPreview, error) {
+	var preview *ScheduledAliasSwitchPreview
+	err := r.db.inTx(func(tx *sql.Tx) error {
+		s, err := scheduledAliasSwitch(tx, id)
+		if err != nil {
+			return err
+		}
+
+		// Switches of an alias are never scheduled at the same time, so the
+		// previous one activates at least a microsecond before.
+		changes, problems, err := r.preflightAliasSwitch(tx, &AliasSwitch{Aliases: map[string]string{s.Alias: s.Product}}, []string{s.Alias}, s.ActivateAt.Add(-time.Microsecond))
+		if err != nil {
+			return err
+		}
+		preview = &ScheduledAliasSwitchPreview{ScheduledAliasSwitch: s, Problems: problems}
+		if len(changes) > 0 {
+			preview.Change = &changes[0]
+		}
+		return nil
+	})
+	return preview, err
+}
+
+// PromoteAliasSwitches promotes the switches that are due, and returns how
+// many were promoted.
+func (d *DB) PromoteAliasSwitches() (int, error) {
+	now := time.Now().UTC()
+	rows, err := d.Query(
+		`SELECT DISTINCT alias FROM mirror_alias_schedule
+			WHERE promoted_at IS NULL AND activate_at <= ?`,
+		now)
+	if err != nil {
+		return 0, err
+	}
+	aliases := []string{}
+	for rows.Next() {
+		var alias string
+		if err := rows.Scan(&alias); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		aliases = append(aliases, alias)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	promoted := 0
+	for _, alias := range aliases {
+		err := d.inTx(func(tx *sql.Tx) error {
+			n, err := promoteAliasSwitches(tx, alias, now)
+			promoted += n
+			return err
+		})
+		if err != nil {
+			return promoted, err
+		}
+	}
+	return promoted, nil
+}
+
+// StartAliasPromoter promotes the switches that are due every interval, in
+// the background. Aliases point to their new product as soon as a switch is
+// due either way, promotion keeps mirror_aliases up to date for its other
+// readers.
+func (d *DB) StartAliasPromoter(interval time.Duration) {
+	go func() {
+		for {
+			n, err := d.PromoteAliasSwitches()
+			if err != nil {
+				log.Printf("Error promoting scheduled alias switches: %v", err)
+			}
+			if n > 0 {
+				log.Printf("Promoted %d scheduled alias switches", n)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// promoteAliasSwitches applies the switches of an alias that are due, in the
+// order they activated, and returns how many were applied. Each change is
+// recorded in the history at the time its switch activated, by the actor who
+// scheduled it. Switches that would create an alias with the name of a
+// product are dropped.
+func promoteAliasSwitches(tx *sql.Tx, alias string, now time.Time) (int, error) {
+	rows, err := tx.Query(
+		`SELECT id, alias, product, activate_at, created_at, actor FROM mirror_alias_schedule
+			WHERE alias = ? AND promoted_at IS NULL AND activate_at <= ?
+			ORDER BY activate_at, id`,
+		alias, now)
+	if err != nil {
+		return 0, err
+	}
+	switches := []*ScheduledAliasSwitch{}
+	for rows.Next() {
+		s, err := scanScheduledAliasSwitch(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		switches = append(switches, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	promoted := 0
+	for _, s := range switches {
+		// Another instance may have promoted it in the meantime.
+		result, err := tx.Exec(
+			"UPDATE mirror_alias_schedule SET promoted_at = ? WHERE id = ? AND promoted_at IS NULL",
+			now, s.ID)
+		if err != nil {
+			return promoted, err
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return promoted, err
+		} else if n == 0 {
+			continue
+		}
+
+		var current string
+		err = tx.QueryRow(
+			"SELECT related_product FROM mirror_aliases WHERE alias = ?",
+			s.Alias).Scan(&current)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return promoted, err
+		}
+		// A product of the same name may have been created since the switch
+		// was scheduled, e.g. by bouncer-admin.
+		if current == "" {
+			if err := checkAliasName(tx, s.Alias); errors.Is(err, ErrConflict) {
+				log.Printf("Dropping scheduled switch %d: %v", s.ID, err)
+				continue
+			} else if err != nil {
+				return promoted, err
+			}
+		}
+		if err := setAlias(tx, s.Actor, s.Alias, current, s.Product, s.ActivateAt); err != nil {
+			return promoted, err
+		}
+		promoted++
+	}
+	return promoted, nil
+}
+
+// currentAlias promotes the switches of an alias that are due, then returns
+// the product it points to, or "" when it isn't an alias. Changes to aliases
+// start with it, so that they apply on top of the due switches.
+func currentAlias(tx *sql.Tx, alias string) (string, error) {
+	now := time.Now().UTC()
+	if _, err := promoteAliasSwitches(tx, alias, now); err != nil {
+		return "", err
+	}
+	return aliasProductAt(tx, alias, now)
+}
+
+// aliasProductAt returns the product that an alias points to at a given time,
+// including the switches that will be due by then, or "" when it isn't an
+// alias.
+func aliasProductAt(q queryer, alias string, at time.Time) (product string, err error) {
+	// Due switches come first, in a single query since AliasFor runs for
+	// every request.
+	err = q.QueryRow(
+		`SELECT product FROM (
+			SELECT product, 0 AS promoted, activate_at, id FROM mirror_alias_schedule
+				WHERE alias = ? AND promoted_at IS NULL AND activate_at <= ?
+			UNION ALL
+			SELECT related_product, 1, NULL, id FROM mirror_aliases
+				WHERE alias = ?
+		) AS candidates
+			ORDER BY promoted, activate_at DESC, id DESC
+			LIMIT 1`,
+		alias, at.UTC(), alias).Scan(&product)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return product, err
+}
+
+// dueAliasSwitches returns the products that aliases point to because of
+// switches that are due but haven't been promoted yet, keyed by lowercased
+// alias.
+func dueAliasSwitches(q queryer, aliases []string) (map[string]string, error) {
+	due := map[string]string{}
+	if len(aliases) == 0 {
+		return due, nil
+	}
+
+	rows, err := q.Query(
+		`SELECT alias, product FROM mirror_alias_schedule
+			WHERE promoted_at IS NULL AND activate_at <= ? AND alias IN (`+placeholders(len(aliases))+`)
+			ORDER BY activate_at, id`,
+		append([]any{time.Now().UTC()}, args(aliases)...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var alias, product string
+		if err := rows.Scan(&alias, &product); err != nil {
+			return nil, err
+		}
+		// The last due switch wins.
+		due[strings.ToLower(alias)] = product
+	}
+	return due, rows.Err()
+}
+
+func scheduledAliasSwitch(q queryer, id int64) (*ScheduledAliasSwitch, error) {
+	rows, err := q.Query(
+		`SELECT id, alias, product, activate_at, created_at, actor FROM mirror_alias_schedule
+			WHERE id = ? AND promoted_at IS NULL`,
+		id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, sql.ErrNoRows
+	}
+	return scanScheduledAliasSwitch(rows)
+}
+
+func scanScheduledAliasSwitch(rows *sql.Rows) (*ScheduledAliasSwitch, error) {
+	var s ScheduledAliasSwitch
+	var activateAt, createdAt string
+	if err := rows.Scan(&s.ID, &s.Alias, &s.Product, &activateAt, &createdAt, &s.Actor); err != nil {
+		return nil, err
+	}
+	var err error
+	if s.ActivateAt, err = parseDBTime(activateAt); err != nil {
+		return nil, err
+	}
+	if s.CreatedAt, err = parseDBTime(createdAt); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
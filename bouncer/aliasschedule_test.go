@@ -0,0 +1,207 @@
+package bouncer
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mozilla-services/go-bouncer/bouncerapi"
+	"github.com/stretchr/testify/assert"
+)
+
+// insertScheduledSwitch schedules a switch at any time, unlike
+// ScheduleAliasSwitch which only schedules switches in the future.
+func insertScheduledSwitch(t *testing.T, alias, product string, at time.Time) int64 {
+	result, err := testDB.Exec(
+		`INSERT INTO mirror_alias_schedule (alias, product, activate_at, created_at, actor)
+			VALUES (?, ?, ?, ?, ?)`,
+		alias, product, at.UTC(), time.Now().UTC(), "scheduler")
+	assert.NoError(t, err)
+	id, err := result.LastInsertId()
+	assert.NoError(t, err)
+	return id
+}
+
+func deleteScheduleTestAlias(t *testing.T, alias string) {
+	_, err := testDB.Exec("DELETE FROM mirror_alias_schedule WHERE alias = ?", alias)
+	assert.NoError(t, err)
+	_, err = testDB.Exec("DELETE FROM mirror_alias_history WHERE alias = ?", alias)
+	assert.NoError(t, err)
+	_, err = testDB.Exec("DELETE FROM mirror_aliases WHERE alias = ?", alias)
+	assert.NoError(t, err)
+}
+
+func TestScheduledAliasSwitchDue(t *testing.T) {
+	defer deleteScheduleTestAlias(t, "firefox-schedule-test")
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	insertScheduledSwitch(t, "firefox-schedule-test", "Firefox-127.0", now.Add(-time.Hour))
+	future := insertScheduledSwitch(t, "firefox-schedule-test", "Firefox", now.Add(time.Hour))
+
+	// Due switches apply before they are promoted.
+	product, err := testDB.AliasFor("firefox-schedule-test")
+	assert.NoError(t, err)
+	assert.Equal(t, "Firefox-127.0", product)
+
+	related, err := testDB.AliasesFor([]string{"firefox-schedule-test", "firefox-latest"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"firefox-schedule-test": "Firefox-127.0", "firefox-latest": "Firefox"}, related)
+
+	for _, tc := range []struct {
+		at       time.Time
+		expected string
+	}{
+		{now.Add(-2 * time.Hour), "firefox-schedule-test"},
+		{now, "Firefox-127.0"},
+		{now.Add(2 * time.Hour), "Firefox"},
+	} {
+		product, err := testDB.AliasAt("firefox-schedule-test", tc.at)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, product, "at %s", tc.at)
+	}
+
+	promoted, err := testDB.PromoteAliasSwitches()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, promoted)
+	promoted, err = testDB.PromoteAliasSwitches()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, promoted)
+
+	var current string
+	assert.NoError(t, testDB.QueryRow("SELECT related_product FROM mirror_aliases WHERE alias = 'firefox-schedule-test'").Scan(&current))
+	assert.Equal(t, "Firefox-127.0", current)
+
+	// The change is in the history, at the time the switch was due.
+	entries, err := testDB.AliasHistory("firefox-schedule-test", 10, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []AliasHistoryEntry{
+		{Alias: "firefox-schedule-test", NewProduct: "Firefox-127.0", Time: now.Add(-time.Hour), Actor: "scheduler"},
+	}, entries)
+	product, err = testDB.AliasAt("firefox-schedule-test", now.Add(-2*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, "firefox-schedule-test", product)
+
+	switches, err := testDB.ScheduledAliasSwitches(10, 0)
+	assert.NoError(t, err)
+	assert.Len(t, switches, 1)
+	assert.Equal(t, future, switches[0].ID)
+
+	// Changes apply on top of the due switches.
+	insertScheduledSwitch(t, "firefox-schedule-test", "Firefox-SSL", now.Add(-time.Minute))
+	_, err = testDB.PutAlias("releng", "firefox-schedule-test", "Firefox-127.0-SSL")
+	assert.NoError(t, err)
+	entries, err = testDB.AliasHistory("firefox-schedule-test", 10, 0)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+	assert.Equal(t, "Firefox-SSL", entries[0].OldProduct)
+	assert.Equal(t, "Firefox-127.0-SSL", entries[0].NewProduct)
+	assert.Equal(t, "Firefox-127.0", entries[1].OldProduct)
+	assert.Equal(t, "Firefox-SSL", entries[1].NewProduct)
+}
+
+func TestScheduledAliasSwitchProductName(t *testing.T) {
+	defer deleteScheduleTestAlias(t, "Firefox-127.0")
+	defer deleteScheduleTestAlias(t, "firefox-schedule-name-test")
+
+	// Switches can't be scheduled to create an alias with the name of a
+	// product.
+	h := newTestAdminHandler()
+	w := adminRequest(h, "POST", "/admin/v1/scheduled-switches", "releng-token", `{"aliases": {"Firefox-127.0": "Firefox"}, "activate_at": "2099-01-01T00:00:00Z"}`)
+	assert.Equal(t, 422, w.Code)
+	assert.Contains(t, w.Body.String(), "Firefox-127.0: the name is taken by a product")
+
+	// Products can't be created with the name of a scheduled alias.
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	insertScheduledSwitch(t, "firefox-schedule-name-test", "Firefox", now.Add(time.Hour))
+	_, err := testDB.PutProduct("releng", "firefox-schedule-name-test", false)
+	assert.ErrorIs(t, err, ErrConflict)
+
+	// Switches whose name was taken by a product in the meantime, e.g. by
+	// bouncer-admin, are dropped when they are promoted.
+	insertScheduledSwitch(t, "Firefox-127.0", "Firefox", now.Add(-time.Hour))
+	promoted, err := testDB.PromoteAliasSwitches()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, promoted)
+	var count int
+	assert.NoError(t, testDB.QueryRow("SELECT COUNT(*) FROM mirror_aliases WHERE alias = 'Firefox-127.0'").Scan(&count))
+	assert.Equal(t, 0, count)
+	product, err := testDB.AliasFor("Firefox-127.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "Firefox-127.0", product)
+}
+
+func TestAdminHandlerScheduledSwitch(t *testing.T) {
+	defer deleteScheduleTestAlias(t, "firefox-schedule-admin-test")
+	_, err := testDB.PutAlias("test", "firefox-schedule-admin-test", "Firefox")
+	assert.NoError(t, err)
+
+	h := newTestAdminHandler()
+	at := time.Now().Add(time.Hour).UTC().Truncate(time.Microsecond)
+	body := fmt.Sprintf(`{"aliases": {"firefox-schedule-admin-test": "Firefox-127.0"}, "activate_at": %q}`, at.Format(time.RFC3339Nano))
+
+	w := adminRequest(h, "POST", "/admin/v1/scheduled-switches", "releng-token", `{"aliases": {"firefox-schedule-admin-test": "Firefox-127.0"}, "activate_at": "2024-06-01T00:00:00Z"}`)
+	assert.Equal(t, 400, w.Code)
+	w = adminRequest(h, "POST", "/admin/v1/scheduled-switches", "releng-token", `{"aliases": {"firefox-schedule-admin-test": "Firefox-nightly-latest"}, "activate_at": "2099-01-01T00:00:00Z"}`)
+	assert.Equal(t, 422, w.Code)
+
+	w = adminRequest(h, "POST", "/admin/v1/scheduled-switches", "releng-token", fmt.Sprintf(`{"aliases": {"firefox-schedule-admin-test": "Firefox-127.0"}, "activate_at": %q, "dry_run": true}`, at.Format(time.RFC3339Nano)))
+	assert.Equal(t, 200, w.Code)
+	var resp ScheduledSwitchResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Empty(t, resp.Scheduled)
+	assert.Len(t, resp.Changes, 1)
+
+	w = adminRequest(h, "POST", "/admin/v1/scheduled-switches", "releng-token", body)
+	assert.Equal(t, 201, w.Code)
+	resp = ScheduledSwitchResponse{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Len(t, resp.Scheduled, 1)
+	id := resp.Scheduled[0].ID
+	assert.Equal(t, "Firefox-127.0", resp.Scheduled[0].Product)
+	assert.Equal(t, at, resp.Scheduled[0].ActivateAt)
+	assert.Equal(t, "releng", resp.Scheduled[0].Actor)
+
+	// Not due yet.
+	product, err := testDB.AliasFor("firefox-schedule-admin-test")
+	assert.NoError(t, err)
+	assert.Equal(t, "Firefox", product)
+
+	w = adminRequest(h, "POST", "/admin/v1/scheduled-switches", "releng-token", body)
+	assert.Equal(t, 409, w.Code)
+	w = adminRequest(h, "DELETE", "/admin/v1/products/Firefox-127.0", "releng-token", "")
+	assert.Equal(t, 409, w.Code)
+
+	w = adminRequest(h, "GET", "/admin/v1/scheduled-switches", "releng-token", "")
+	assert.Equal(t, 200, w.Code)
+	var page bouncerapi.Page[ScheduledAliasSwitch]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Equal(t, resp.Scheduled, page.Items)
+
+	w = adminRequest(h, "GET", fmt.Sprintf("/admin/v1/scheduled-switches/%d", id), "releng-token", "")
+	assert.Equal(t, 200, w.Code)
+	var preview ScheduledAliasSwitchPreview
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &preview))
+	assert.Equal(t, id, preview.ID)
+	assert.Equal(t, "Firefox", preview.Change.OldProduct)
+	assert.Equal(t, "Firefox-127.0", preview.Change.NewProduct)
+	assert.Len(t, preview.Change.Resolutions, 3)
+	assert.Empty(t, preview.Problems)
+
+	w = adminRequest(h, "DELETE", fmt.Sprintf("/admin/v1/scheduled-switches/%d", id), "auditor-token", "")
+	assert.Equal(t, 403, w.Code)
+	w = adminRequest(h, "DELETE", fmt.Sprintf("/admin/v1/scheduled-switches/%d", id), "releng-token", "")
+	assert.Equal(t, 204, w.Code)
+	w = adminRequest(h, "GET", fmt.Sprintf("/admin/v1/scheduled-switches/%d", id), "releng-token", "")
+	assert.Equal(t, 404, w.Code)
+	w = adminRequest(h, "DELETE", fmt.Sprintf("/admin/v1/scheduled-switches/%d", id), "releng-token", "")
+	assert.Equal(t, 404, w.Code)
+
+	// Due switches can't be cancelled.
+	due := insertScheduledSwitch(t, "firefox-schedule-admin-test", "Firefox-127.0", time.Now().Add(-time.Minute))
+	w = adminRequest(h, "DELETE", fmt.Sprintf("/admin/v1/scheduled-switches/%d", due), "releng-token", "")
+	assert.Equal(t, 409, w.Code)
+	product, err = testDB.AliasFor("firefox-schedule-admin-test")
+	assert.NoError(t, err)
+	assert.Equal(t, "Firefox-127.0", product)
+}
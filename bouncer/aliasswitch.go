@@ -0,0 +1,289 @@
+package bouncer
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxAliasSwitchSize is the maximum number of aliases changed by a switch.
+const maxAliasSwitchSize = 500
+
+// AliasSwitch points many aliases to new products at once, e.g. for a
+// release.
+type AliasSwitch struct {
+	// Aliases maps aliases to their new product.
+	Aliases map[string]string `json:"aliases"`
+	// ExpectedOSes and ExpectedLanguages are what every new product must be
+	// available in. They default to the OSes and languages of the product
+	// that each alias currently points to.
+	ExpectedOSes      []string `json:"expected_oses"`
+	ExpectedLanguages []string `json:"expected_languages"`
+	// DryRun runs the preflight check and returns the diff without applying
+	// the switch.
+	DryRun bool `json:"dry_run"`
+}
+
+// PreflightError lists the reasons why a switch cannot be applied.
+type PreflightError struct {
+	Problems []string `json:"problems"`
+}
+
+func (e *PreflightError) Error() string {
+	return "preflight check failed: " + strings.Join(e.Problems, "; ")
+}
+
+// AliasChange is the change of an alias made by a switch. Resolutions lists
+// the URLs that change, for unpinned requests and with :lang left as is.
+type AliasChange struct {
+	Alias            string             `json:"alias"`
+	OldProduct       string             `json:"old_product,omitempty"`
+	NewProduct       string             `json:"new_product"`
+	AddedLanguages   []string           `json:"added_languages,omitempty"`
+	RemovedLanguages []string           `json:"removed_languages,omitempty"`
+	Resolutions      []ResolutionChange `json:"resolutions"`
+}
+
+// ResolutionChange is the change of the URL an alias resolves to for an OS.
+// Old or New is empty when the alias didn't or won't resolve for the OS.
+type ResolutionChange struct {
+	OS  string `json:"os"`
+	Old string `json:"old,omitempty"`
+	New string `json:"new,omitempty"`
+}
+
+// aliasTarget is a product along with what it is available in.
+type aliasTarget struct {
+	name      string
+	languages []string
+	// urls maps OS names to the URL of the product, with :lang left as is.
+	urls map[string]string
+}
+
+// SwitchAliases checks that every new product is available in the expected
+// OSes and languages, then points the aliases to them in a single
+// transaction, so that clients never see a mix of old and new products. A
+// *PreflightError is returned when the check fails, in which case nothing is
+// changed.
+func (r *Resolver) SwitchAliases(actor string, s *AliasSwitch) ([]AliasChange, error) {
+	aliases, err := s.sortedAliases()
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []AliasChange
+	err = r.db.inTx(func(tx *sql.Tx) error {
+		now := time.Now().UTC()
+		for _, alias := range aliases {
+			if _, err := promoteAliasSwitches(tx, alias, now); err != nil {
+				return err
+			}
+		}
+
+		var problems []string
+		changes, problems, err = r.preflightAliasSwitch(tx, s, aliases, now)
+		if err != nil {
+			return err
+		}
+		if len(problems) > 0 {
+			return &PreflightError{Problems: problems}
+		}
+		if s.DryRun {
+			return nil
+		}
+
+		for _, change := range changes {
+			if err := setAlias(tx, actor, change.Alias, change.OldProduct, change.NewProduct, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// sortedAliases returns the aliases of a switch, sorted.
+func (s *AliasSwitch) sortedAliases() ([]string, error) {
+	if len(s.Aliases) == 0 || len(s.Aliases) > maxAliasSwitchSize {
+		return nil, fmt.Errorf("%w: a switch must have between 1 and %d aliases", ErrInvalid, maxAliasSwitchSize)
+	}
+
+	aliases := make([]string, 0, len(s.Aliases))
+	for alias := range s.Aliases {
+		aliases = append(aliases, alias)
+	}
+	slices.Sort(aliases)
+	return aliases, nil
+}
+
+// preflightAliasSwitch returns the changes of a switch applied at a given
+// time, relative to the products that the aliases point to then, along with
+// the problems that prevent it from being applied.
+func (r *Resolver) preflightAliasSwitch(tx *sql.Tx, s *AliasSwitch, aliases []string, at time.Time) ([]AliasChange, []string, error) {
+	problems := []string{}
+	for _, os := range s.ExpectedOSes {
+		var id string
+		err := tx.QueryRow("SELECT id FROM mirror_os WHERE name = ?", os).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			problems = append(problems, fmt.Sprintf("unknown os %s", os))
+		} else if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	changes := make([]AliasChange, 0, len(aliases))
+	for _, alias := range aliases {
+		change := AliasChange{Alias: alias, NewProduct: s.Aliases[alias]}
+
+		var old *aliasTarget
+		var err error
+		if change.OldProduct, err = aliasProductAt(tx, alias, at); err != nil {
+			return nil, nil, err
+		}
+		if change.OldProduct != "" {
+			old, err = r.loadAliasTarget(tx, change.OldProduct)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return nil, nil, err
+			}
+		} else if err := checkAliasName(tx, alias); errors.Is(err, ErrConflict) {
+			// New aliases would shadow a product of the same name.
+			problems = append(problems, fmt.Sprintf("%s: the name is taken by a product", alias))
+		} else if err != nil {
+			return nil, nil, err
+		}
+
+		new, err := r.loadAliasTarget(tx, change.NewProduct)
+		if errors.Is(err, sql.ErrNoRows) {
+			problems = append(problems, fmt.Sprintf("%s: unknown product %s", alias, change.NewProduct))
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		problems = append(problems, preflightAliasTarget(alias, s, old, new)...)
+		change.AddedLanguages, change.RemovedLanguages = languagesDiff(old, new)
+		change.Resolutions = resolutionsDiff(old, new)
+		changes = append(changes, change)
+	}
+	return changes, problems, nil
+}
+
+// loadAliasTarget returns a product along with its languages and URLs.
+// sql.ErrNoRows is returned when it doesn't exist.
+func (r *Resolver) loadAliasTarget(tx *sql.Tx, product string) (*aliasTarget, error) {
+	id, sslOnly, err := productRow(tx, product)
+	if err != nil {
+		return nil, err
+	}
+
+	target := &aliasTarget{name: product, urls: map[string]string{}}
+	if target.languages, err = productLanguages(tx, id); err != nil {
+		return nil, err
+	}
+
+	locations, err := locationPaths(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, location := range locations {
+		url, err := r.locationURL(r.db.BaseURLsFor, false, ":lang", product, sslOnly, location.Path)
+		if err != nil {
+			// The location cannot be resolved, which the preflight check
+			// reports for expected OSes.
+			continue
+		}
+		target.urls[location.OS] = url
+	}
+	return target, nil
+}
+
+// preflightAliasTarget returns the problems that prevent an alias from being
+// switched from old (nil for new aliases) to new.
+func preflightAliasTarget(alias string, s *AliasSwitch, old, new *aliasTarget) []string {
+	problems := []string{}
+
+	oses := s.ExpectedOSes
+	if len(oses) == 0 && old != nil {
+		for os := range old.urls {
+			oses = append(oses, os)
+		}
+		slices.Sort(oses)
+	}
+	for _, os := range oses {
+		if _, ok := new.urls[os]; !ok {
+			problems = append(problems, fmt.Sprintf("%s: product %s has no valid location for %s", alias, new.name, os))
+		}
+	}
+
+	// Products without languages are available in all languages.
+	if len(new.languages) == 0 {
+		return problems
+	}
+	languages := s.ExpectedLanguages
+	if len(languages) == 0 && old != nil {
+		if len(old.languages) == 0 {
+			return append(problems, fmt.Sprintf("%s: product %s is not available in all languages like %s", alias, new.name, old.name))
+		}
+		languages = old.languages
+	}
+	for _, lang := range languages {
+		if !slices.Contains(new.languages, lang) {
+			problems = append(problems, fmt.Sprintf("%s: product %s is not available in %s", alias, new.name, lang))
+		}
+	}
+	return problems
+}
+
+// languagesDiff returns the languages that new has and old doesn't, and
+// conversely. Products available in all languages are not compared.
+func languagesDiff(old, new *aliasTarget) (added, removed []string) {
+	if old == nil || len(old.languages) == 0 || len(new.languages) == 0 {
+		return nil, nil
+	}
+	for _, lang := range new.languages {
+		if !slices.Contains(old.languages, lang) {
+			added = append(added, lang)
+		}
+	}
+	for _, lang := range old.languages {
+		if !slices.Contains(new.languages, lang) {
+			removed = append(removed, lang)
+		}
+	}
+	return added, removed
+}
+
+// resolutionsDiff returns the URLs that change, by OS.
+func resolutionsDiff(old, new *aliasTarget) []ResolutionChange {
+	oses := []string{}
+	for os := range new.urls {
+		oses = append(oses, os)
+	}
+	if old != nil {
+		for os := range old.urls {
+			if _, ok := new.urls[os]; !ok {
+				oses = append(oses, os)
+			}
+		}
+	}
+	slices.Sort(oses)
+
+	changes := []ResolutionChange{}
+	for _, os := range oses {
+		change := ResolutionChange{OS: os, New: new.urls[os]}
+		if old != nil {
+			change.Old = old.urls[os]
+		}
+		if change.Old != change.New {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
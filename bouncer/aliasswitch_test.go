@@ -0,0 +1,164 @@
+package bouncer
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminHandlerAliasSwitchDryRun(t *testing.T) {
+	h := newTestAdminHandler()
+
+	w := adminRequest(h, "POST", "/admin/v1/alias-switches", "releng-token", `{
+		"aliases": {"firefox-latest": "Firefox-127.0", "firefox-latest-ssl": "Firefox-SSL"},
+		"dry_run": true
+	}`)
+	assert.Equal(t, 200, w.Code)
+
+	var resp AliasSwitchResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.False(t, resp.Applied)
+	assert.Equal(t, []AliasChange{
+		{
+			Alias:      "firefox-latest",
+			OldProduct: "Firefox",
+			NewProduct: "Firefox-127.0",
+			Resolutions: []ResolutionChange{
+				{
+					OS:  "osx",
+					Old: "http://download.cdn.mozilla.net/pub/firefox/releases/39.0/mac/:lang/Firefox%2039.0.dmg",
+					New: "http://download.cdn.mozilla.net/pub/firefox/releases/127.0/mac/:lang/Firefox%20Setup%20127.0.exe",
+				},
+				{
+					OS:  "win",
+					Old: "http://download.cdn.mozilla.net/pub/firefox/releases/39.0/win32/:lang/Firefox%20Setup%2039.0.exe",
+					New: "http://download.cdn.mozilla.net/pub/firefox/releases/127.0/win32/:lang/Firefox%20Setup%20127.0.exe",
+				},
+				{
+					OS:  "win64",
+					Old: "http://download.cdn.mozilla.net/pub/firefox/releases/39.0/win64/:lang/Firefox%20Setup%2039.0.exe",
+					New: "http://download.cdn.mozilla.net/pub/firefox/releases/127.0/win64/:lang/Firefox%20Setup%20127.0.exe",
+				},
+			},
+		},
+		// Unchanged.
+		{
+			Alias:       "firefox-latest-ssl",
+			OldProduct:  "Firefox-SSL",
+			NewProduct:  "Firefox-SSL",
+			Resolutions: []ResolutionChange{},
+		},
+	}, resp.Changes)
+
+	product, err := testDB.AliasFor("firefox-latest")
+	assert.NoError(t, err)
+	assert.Equal(t, "Firefox", product)
+}
+
+func TestAdminHandlerAliasSwitchPreflight(t *testing.T) {
+	h := newTestAdminHandler()
+
+	for _, tc := range []struct {
+		body     string
+		problems []string
+	}{
+		{
+			`{"aliases": {"firefox-latest": "Firefox-nightly-latest", "firefox-latest-ssl": "unknown-product"}}`,
+			[]string{
+				"firefox-latest: product Firefox-nightly-latest has no valid location for osx",
+				"firefox-latest-ssl: unknown product unknown-product",
+			},
+		},
+		{
+			`{"aliases": {"firefox-latest": "Firefox-SSL"}, "expected_oses": ["win", "typo"], "expected_languages": ["en-US", "de"]}`,
+			[]string{
+				"unknown os typo",
+				"firefox-latest: product Firefox-SSL has no valid location for typo",
+				"firefox-latest: product Firefox-SSL is not available in de",
+			},
+		},
+		// Products available in all languages can't be replaced by products
+		// available in some languages only.
+		{
+			`{"aliases": {"firefox-devedition-latest": "Firefox"}}`,
+			[]string{"firefox-devedition-latest: product Firefox is not available in all languages like Devedition-128.0b1"},
+		},
+		// New aliases can't shadow a product.
+		{
+			`{"aliases": {"Firefox-127.0": "Firefox"}}`,
+			[]string{"Firefox-127.0: the name is taken by a product"},
+		},
+	} {
+		w := adminRequest(h, "POST", "/admin/v1/alias-switches", "releng-token", tc.body)
+		assert.Equal(t, 422, w.Code, tc.body)
+
+		var preflightErr PreflightError
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &preflightErr))
+		assert.Equal(t, tc.problems, preflightErr.Problems, tc.body)
+	}
+
+	w := adminRequest(h, "POST", "/admin/v1/alias-switches", "releng-token", `{"aliases": {"firefox-latest": "Firefox-nightly-latest", "firefox-beta-latest": "Firefox-127.0"}}`)
+	assert.Equal(t, 422, w.Code)
+
+	// Nothing changed, including the valid changes.
+	for alias, expected := range map[string]string{"firefox-latest": "Firefox", "firefox-beta-latest": "Firefox"} {
+		product, err := testDB.AliasFor(alias)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, product)
+	}
+
+	for _, body := range []string{`{"aliases": {}}`, `{"aliases": {"bad alias": "Firefox"}}`} {
+		w := adminRequest(h, "POST", "/admin/v1/alias-switches", "releng-token", body)
+		assert.Equal(t, 400, w.Code, body)
+	}
+
+	w = adminRequest(h, "POST", "/admin/v1/alias-switches", "auditor-token", `{"aliases": {"firefox-latest": "Firefox-127.0"}}`)
+	assert.Equal(t, 403, w.Code)
+}
+
+func TestAdminHandlerAliasSwitch(t *testing.T) {
+	h := newTestAdminHandler()
+
+	w := adminRequest(h, "POST", "/admin/v1/alias-switches", "releng-token", `{
+		"aliases": {"firefox-switch-test-a": "Firefox-127.0", "firefox-switch-test-b": "Firefox-127.0-SSL"}
+	}`)
+	assert.Equal(t, 200, w.Code)
+
+	var resp AliasSwitchResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.True(t, resp.Applied)
+	assert.Len(t, resp.Changes, 2)
+	assert.Empty(t, resp.Changes[0].OldProduct)
+	assert.Len(t, resp.Changes[1].Resolutions, 3)
+	assert.Equal(t, "https://download-installer.cdn.mozilla.net/pub/firefox/releases/127.0/mac/:lang/Firefox%20Setup%20127.0.exe", resp.Changes[1].Resolutions[0].New)
+
+	// The new products must have the OSes of the current ones.
+	w = adminRequest(h, "POST", "/admin/v1/alias-switches", "releng-token", `{
+		"aliases": {"firefox-switch-test-a": "Firefox-127.0b9", "firefox-switch-test-b": "Firefox-SSL"}
+	}`)
+	assert.Equal(t, 422, w.Code)
+
+	w = adminRequest(h, "POST", "/admin/v1/alias-switches", "releng-token", `{
+		"aliases": {"firefox-switch-test-a": "Firefox", "firefox-switch-test-b": "Firefox-SSL"},
+		"expected_languages": ["en-US"]
+	}`)
+	assert.Equal(t, 200, w.Code)
+
+	for alias, expected := range map[string]string{"firefox-switch-test-a": "Firefox", "firefox-switch-test-b": "Firefox-SSL"} {
+		product, err := testDB.AliasFor(alias)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, product)
+	}
+
+	// Every alias change is in the audit log.
+	entries, err := testDB.AuditLog(2, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "firefox-switch-test-b", entries[0].Key)
+	assert.JSONEq(t, `{"alias": "firefox-switch-test-b", "product": "Firefox-127.0-SSL"}`, string(entries[0].Old))
+	assert.JSONEq(t, `{"alias": "firefox-switch-test-b", "product": "Firefox-SSL"}`, string(entries[0].New))
+	assert.Equal(t, "firefox-switch-test-a", entries[1].Key)
+
+	assert.NoError(t, testDB.DeleteAlias("test", "firefox-switch-test-a"))
+	assert.NoError(t, testDB.DeleteAlias("test", "firefox-switch-test-b"))
+}
@@ -0,0 +1,221 @@
+package bouncer
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/mozilla-services/go-bouncer/bouncerapi"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+
+	// maxBatchRequestBytes is the maximum size of a batch resolution body.
+	maxBatchRequestBytes = 1 << 20
+)
+
+// The types of the JSON API live in bouncerapi, so that clients don't depend
+// on this package.
+type (
+	Resolution           = bouncerapi.Resolution
+	RolloutBucket        = bouncerapi.RolloutBucket
+	ExperimentAssignment = bouncerapi.ExperimentAssignment
+	Metadata             = bouncerapi.Metadata
+	BatchItem            = bouncerapi.BatchItem
+	BatchRequest         = bouncerapi.BatchRequest
+	BatchResult          = bouncerapi.BatchResult
+	BatchResponse        = bouncerapi.BatchResponse
+	Alias                = bouncerapi.Alias
+	Product              = bouncerapi.Product
+	HealthResult         = bouncerapi.HealthResult
+)
+
+// APIHandler serves the JSON API under /api/v1/. None of its endpoints modify
+// data.
+type APIHandler struct {
+	bouncer *BouncerHandler
+	mux     *http.ServeMux
+
+	CacheTime time.Duration
+}
+
+// NewAPIHandler returns an APIHandler using the database and trusted sites of
+// bouncer.
+func NewAPIHandler(bouncer *BouncerHandler, cacheTime time.Duration) *APIHandler {
+	h := &APIHandler{
+		bouncer:   bouncer,
+		mux:       http.NewServeMux(),
+		CacheTime: cacheTime,
+	}
+	h.mux.HandleFunc("GET /api/v1/aliases", h.aliases)
+	h.mux.HandleFunc("GET /api/v1/products", h.products)
+	h.mux.HandleFunc("GET /api/v1/products/{name}", h.product)
+	h.mux.HandleFunc("POST /api/v1/resolve", h.resolveBatch)
+	h.mux.HandleFunc("OPTIONS /api/v1/resolve", h.resolveBatchPreflight)
+	return h
+}
+
+func (h *APIHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	h.mux.ServeHTTP(w, req)
+}
+
+func (h *APIHandler) aliases(w http.ResponseWriter, req *http.Request) {
+	limit, offset, err := pagination(req.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Fetch one more item to know whether there is a next page.
+	aliases, err := h.bouncer.db.Aliases(limit+1, offset)
+	if err != nil {
+		h.internalError(w, err)
+		return
+	}
+	h.writeJSON(w, req, newPage(req, aliases, limit, offset))
+}
+
+func (h *APIHandler) products(w http.ResponseWriter, req *http.Request) {
+	limit, offset, err := pagination(req.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	products, err := h.bouncer.db.Products(limit+1, offset)
+	if err != nil {
+		h.internalError(w, err)
+		return
+	}
+	h.writeJSON(w, req, newPage(req, products, limit, offset))
+}
+
+func (h *APIHandler) product(w http.ResponseWriter, req *http.Request) {
+	product, err := h.bouncer.db.ProductByName(req.PathValue("name"))
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, req)
+		return
+	}
+	if err != nil {
+		h.internalError(w, err)
+		return
+	}
+	h.writeJSON(w, req, product)
+}
+
+func (h *APIHandler) resolveBatch(w http.ResponseWriter, req *http.Request) {
+	h.bouncer.setCORSHeaders(w, req)
+
+	var batch BatchRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxBatchRequestBytes))
+	if err := decoder.Decode(&batch); err != nil {
+		http.Error(w, "Invalid JSON body.", http.StatusBadRequest)
+		return
+	}
+	if len(batch.Items) == 0 || len(batch.Items) > maxBatchSize {
+		http.Error(w, fmt.Sprintf("A batch must have between 1 and %d items.", maxBatchSize), http.StatusBadRequest)
+		return
+	}
+
+	results, err := h.bouncer.ResolveBatch(batch.Items, req.Header.Get("Referer"), req.Header.Get("User-Agent"), h.bouncer.shouldPinHTTPS(req))
+	if err != nil {
+		h.internalError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&BatchResponse{Items: results})
+}
+
+// resolveBatchPreflight answers CORS preflight requests, which browsers send
+// before posting JSON cross-origin.
+func (h *APIHandler) resolveBatchPreflight(w http.ResponseWriter, req *http.Request) {
+	h.bouncer.setCORSHeaders(w, req)
+	if w.Header().Get("Access-Control-Allow-Origin") != "" {
+		w.Header().Set("Access-Control-Allow-Methods", "POST")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		if h.CacheTime > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(h.CacheTime/time.Second)))
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *APIHandler) internalError(w http.ResponseWriter, err error) {
+	http.Error(w, "Internal Server Error.", http.StatusInternalServerError)
+	log.Printf("APIHandler err: %v", err)
+}
+
+// writeJSON writes v as a cacheable JSON response, or a 304 when the client
+// already has it.
+func (h *APIHandler) writeJSON(w http.ResponseWriter, req *http.Request, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		h.internalError(w, err)
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	h.bouncer.setCORSHeaders(w, req)
+	w.Header().Set("ETag", etag)
+	if h.CacheTime > 0 {
+		w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", h.CacheTime/time.Second))
+	}
+
+	if req.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// pagination returns the limit and offset query parameters.
+func pagination(query url.Values) (limit, offset int, err error) {
+	limit = defaultPageLimit
+	if v := query.Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxPageLimit)
+		}
+	}
+	if v := query.Get("offset"); v != "" {
+		offset, err = strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return 0, 0, errors.New("offset must be a positive integer")
+		}
+	}
+	return limit, offset, nil
+}
+
+// newPage returns a page of at most limit items. items may hold one extra
+// item, meaning that there is a next page.
+func newPage[T any](req *http.Request, items []T, limit, offset int) *bouncerapi.Page[T] {
+	page := &bouncerapi.Page[T]{
+		Items:  items,
+		Limit:  limit,
+		Offset: offset,
+	}
+	if len(items) > limit {
+		page.Items = items[:limit]
+
+		query := req.URL.Query()
+		query.Set("limit", strconv.Itoa(limit))
+		query.Set("offset", strconv.Itoa(offset+limit))
+		page.Next = req.URL.Path + "?" + query.Encode()
+	}
+	return page
+}
@@ -0,0 +1,113 @@
+package bouncer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mozilla-services/go-bouncer/bouncerapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIHandlerAliases(t *testing.T) {
+	h := NewAPIHandler(bouncerHandler, time.Minute)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://test/api/v1/aliases?limit=2", nil)
+	h.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, "max-age=60", w.Header().Get("Cache-Control"))
+
+	var page bouncerapi.Page[Alias]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Len(t, page.Items, 2)
+	assert.Equal(t, 2, page.Limit)
+	assert.Equal(t, 0, page.Offset)
+	assert.Equal(t, "/api/v1/aliases?limit=2&offset=2", page.Next)
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "http://test"+page.Next, nil)
+	h.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	var next bouncerapi.Page[Alias]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &next))
+	assert.Len(t, next.Items, 2)
+	assert.NotEqual(t, page.Items[0], next.Items[0])
+
+	// Last page.
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "http://test/api/v1/aliases?limit=1000", nil)
+	h.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	var all bouncerapi.Page[Alias]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &all))
+	assert.Empty(t, all.Next)
+	assert.Contains(t, all.Items, Alias{Alias: "firefox-latest", Product: "Firefox"})
+}
+
+func TestAPIHandlerPagination(t *testing.T) {
+	h := NewAPIHandler(bouncerHandler, time.Minute)
+
+	for _, query := range []string{"limit=0", "limit=1001", "limit=abc", "offset=-1", "offset=abc"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "http://test/api/v1/products?"+query, nil)
+		h.ServeHTTP(w, req)
+		assert.Equal(t, 400, w.Code, query)
+	}
+}
+
+func TestAPIHandlerProduct(t *testing.T) {
+	h := NewAPIHandler(bouncerHandler, time.Minute)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://test/api/v1/products/Firefox-SSL", nil)
+	h.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, `{"name":"Firefox-SSL","ssl_only":true,"languages":["en-GB","en-US"],"oses":["osx","win","win64"]}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "http://test/api/v1/products/unknown-product", nil)
+	h.ServeHTTP(w, req)
+	assert.Equal(t, 404, w.Code)
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "http://test/api/v1/products/Firefox-SSL", nil)
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestAPIHandlerETag(t *testing.T) {
+	h := NewAPIHandler(bouncerHandler, time.Minute)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://test/api/v1/products?limit=5", nil)
+	h.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "http://test/api/v1/products?limit=5", nil)
+	req.Header.Set("If-None-Match", etag)
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+}
+
+func TestAPIHandlerCORS(t *testing.T) {
+	h := NewAPIHandler(bouncerHandler, time.Minute)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://test/api/v1/aliases", nil)
+	req.Header.Set("Origin", "https://www.mozilla.org")
+	h.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "https://www.mozilla.org", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "Origin", w.Header().Get("Vary"))
+}
@@ -0,0 +1,184 @@
+package bouncer
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Attribution signature verification modes.
+const (
+	// AttributionSigOff disables the verification of attribution signatures.
+	AttributionSigOff = "off"
+	// AttributionSigReport verifies attribution signatures and logs failures,
+	// but still forwards the request to the stub attribution service.
+	AttributionSigReport = "report"
+	// AttributionSigEnforce serves the unattributed installer to requests
+	// with an invalid attribution signature.
+	AttributionSigEnforce = "enforce"
+)
+
+const (
+	// maxAttributionCodeLength is the maximum length of an encoded attribution_code.
+	maxAttributionCodeLength = 5000
+	// maxAttributionFieldLength is the maximum length of a known attribution field.
+	maxAttributionFieldLength = 255
+)
+
+var (
+	// This uses '.' as padding because Bedrock is using this library to encode the values:
+	// https://pypi.org/project/querystringsafe-base64/
+	attributionCodeEncoding = base64.URLEncoding.WithPadding('.')
+
+	// defaultAttributionPolicies are used when no policies are configured.
+	defaultAttributionPolicies = []AttributionPolicy{
+		// Only attribute RTAMO downloads when there is a referer header from a
+		// known allowed site.
+		// https://github.com/mozilla-services/go-bouncer/issues/347
+		{ContentPrefix: "rta:", RequireAllowedReferrer: true},
+	}
+
+	errUndecodableAttributionCode = errors.New("attribution_code cannot be decoded")
+
+	errMalformedAttributionSig = errors.New("attribution_sig is not a hex-encoded HMAC-SHA256")
+	errInvalidAttributionSig   = errors.New("attribution_sig does not match any key")
+)
+
+// LoadAttributionKeys reads the HMAC keys shared with the stub attribution
+// service, one key per file. Leading and trailing whitespace is ignored.
+func LoadAttributionKeys(paths []string) ([][]byte, error) {
+	keys := make([][]byte, 0, len(paths))
+	for _, path := range paths {
+		key, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key = bytes.TrimSpace(key)
+		if len(key) == 0 {
+			return nil, fmt.Errorf("attribution key file %s is empty", path)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// verifyAttributionSig checks that sig is the hex-encoded HMAC-SHA256 of code
+// for one of the keys. Several keys are accepted so that they can be rotated.
+func verifyAttributionSig(code, sig string, keys [][]byte) error {
+	decodedSig, err := hex.DecodeString(sig)
+	if err != nil || len(decodedSig) != sha256.Size {
+		return errMalformedAttributionSig
+	}
+
+	for _, key := range keys {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(code))
+		if hmac.Equal(decodedSig, mac.Sum(nil)) {
+			return nil
+		}
+	}
+	return errInvalidAttributionSig
+}
+
+// AttributionCode is a decoded attribution_code. Only the fields known to
+// bouncer are kept.
+type AttributionCode struct {
+	Source     string
+	Medium     string
+	Campaign   string
+	Content    string
+	Experiment string
+	Variation  string
+	UA         string
+}
+
+// ParseAttributionCode decodes and validates an attribution_code, which is a
+// querystring encoded with querystringsafe base64.
+//
+// An error wrapping errUndecodableAttributionCode is returned when the code
+// cannot be decoded at all.
+func ParseAttributionCode(code string) (*AttributionCode, error) {
+	if len(code) > maxAttributionCodeLength {
+		return nil, fmt.Errorf("attribution_code is longer than %d characters", maxAttributionCodeLength)
+	}
+
+	decoded, err := attributionCodeEncoding.DecodeString(code)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errUndecodableAttributionCode, err)
+	}
+	values, err := url.ParseQuery(string(decoded))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errUndecodableAttributionCode, err)
+	}
+
+	field := func(name string) string {
+		if err != nil {
+			return ""
+		}
+		if len(values[name]) > 1 {
+			err = fmt.Errorf("attribution field %s is repeated", name)
+			return ""
+		}
+		value := values.Get(name)
+		if len(value) > maxAttributionFieldLength {
+			err = fmt.Errorf("attribution field %s is longer than %d characters", name, maxAttributionFieldLength)
+			return ""
+		}
+		if strings.IndexFunc(value, isControlCharacter) != -1 {
+			err = fmt.Errorf("attribution field %s contains control characters", name)
+			return ""
+		}
+		return value
+	}
+
+	attributionCode := &AttributionCode{
+		Source:     field("source"),
+		Medium:     field("medium"),
+		Campaign:   field("campaign"),
+		Content:    field("content"),
+		Experiment: field("experiment"),
+		Variation:  field("variation"),
+		UA:         field("ua"),
+	}
+	if err != nil {
+		return nil, err
+	}
+	return attributionCode, nil
+}
+
+func isControlCharacter(r rune) bool {
+	return r < 0x20 || r == 0x7f
+}
+
+// AttributionPolicy is a rule for attribution codes whose content starts with
+// ContentPrefix.
+type AttributionPolicy struct {
+	ContentPrefix string `json:"content_prefix"`
+	// Disallow never attributes matching codes.
+	Disallow bool `json:"disallow"`
+	// RequireAllowedReferrer only attributes matching codes when the
+	// request has a referer header from a trusted site with the
+	// SitePolicyAttribution policy.
+	RequireAllowedReferrer bool `json:"require_allowed_referrer"`
+}
+
+// Allows returns whether the policy allows the attribution of a request.
+func (p AttributionPolicy) Allows(code *AttributionCode, referrer string, sites TrustedSites) bool {
+	if !strings.HasPrefix(code.Content, p.ContentPrefix) {
+		return true
+	}
+	if p.Disallow {
+		return false
+	}
+	if p.RequireAllowedReferrer && !sites.Allows(referrer, SitePolicyAttribution) {
+		return false
+	}
+	return true
+}
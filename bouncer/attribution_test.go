@@ -0,0 +1,113 @@
+package bouncer
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func signAttributionCode(code string, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyAttributionSig(t *testing.T) {
+	oldKey := []byte("old-key")
+	newKey := []byte("new-key")
+	keys := [][]byte{newKey, oldKey}
+	code := "c291cmNlPWdvb2dsZS5jb20mbWVkaXVtPW9yZ2FuaWM."
+
+	assert.NoError(t, verifyAttributionSig(code, signAttributionCode(code, newKey), keys))
+	// Signatures made with the previous key are still accepted during a rotation.
+	assert.NoError(t, verifyAttributionSig(code, signAttributionCode(code, oldKey), keys))
+
+	assert.Equal(t, errInvalidAttributionSig, verifyAttributionSig(code, signAttributionCode(code, []byte("other-key")), keys))
+	assert.Equal(t, errInvalidAttributionSig, verifyAttributionSig("tampered", signAttributionCode(code, newKey), keys))
+	assert.Equal(t, errMalformedAttributionSig, verifyAttributionSig(code, "att-sig", keys))
+	assert.Equal(t, errMalformedAttributionSig, verifyAttributionSig(code, "abcd", keys))
+	assert.Equal(t, errInvalidAttributionSig, verifyAttributionSig(code, signAttributionCode(code, newKey), nil))
+}
+
+func TestLoadAttributionKeys(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first")
+	second := filepath.Join(dir, "second")
+	empty := filepath.Join(dir, "empty")
+	assert.NoError(t, os.WriteFile(first, []byte("first-key\n"), 0o600))
+	assert.NoError(t, os.WriteFile(second, []byte("second-key"), 0o600))
+	assert.NoError(t, os.WriteFile(empty, []byte("\n"), 0o600))
+
+	keys, err := LoadAttributionKeys([]string{first, second})
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("first-key"), []byte("second-key")}, keys)
+
+	_, err = LoadAttributionKeys([]string{first, empty})
+	assert.Error(t, err)
+
+	_, err = LoadAttributionKeys([]string{filepath.Join(dir, "missing")})
+	assert.Error(t, err)
+}
+
+func TestParseAttributionCode(t *testing.T) {
+	encode := func(s string) string {
+		return attributionCodeEncoding.EncodeToString([]byte(s))
+	}
+
+	code, err := ParseAttributionCode("c291cmNlPWFkZG9ucy5tb3ppbGxhLm9yZyZtZWRpdW09cmVmZXJyYWwmY2FtcGFpZ249bm9uLWZ4LWJ1dHRvbiZjb250ZW50PXJ0YTplMkk1WkdJeE5tRTBMVFpsWkdNdE5EZGxZeTFoTVdZMExXSTROakk1TW1Wa01qRXhaSDAmZXhwZXJpbWVudD0obm90IHNldCkmdmFyaWF0aW9uPShub3Qgc2V0KSZ1YT1lZGdlJnZpc2l0X2lkPShub3Qgc2V0KQ..")
+	assert.NoError(t, err)
+	assert.Equal(t, &AttributionCode{
+		Source:     "addons.mozilla.org",
+		Medium:     "referral",
+		Campaign:   "non-fx-button",
+		Content:    "rta:e2I5ZGIxNmE0LTZlZGMtNDdlYy1hMWY0LWI4NjI5MmVkMjExZH0",
+		Experiment: "(not set)",
+		Variation:  "(not set)",
+		UA:         "edge",
+	}, code)
+
+	// Unknown fields are ignored.
+	code, err = ParseAttributionCode(encode("source=google.com&dlsource=mozorg"))
+	assert.NoError(t, err)
+	assert.Equal(t, &AttributionCode{Source: "google.com"}, code)
+
+	_, err = ParseAttributionCode("source%3Dgoogle.com")
+	assert.ErrorIs(t, err, errUndecodableAttributionCode)
+
+	_, err = ParseAttributionCode(encode("source=google.com&source=bing.com"))
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, errUndecodableAttributionCode)
+
+	_, err = ParseAttributionCode(encode("campaign=" + strings.Repeat("a", maxAttributionFieldLength+1)))
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, errUndecodableAttributionCode)
+
+	_, err = ParseAttributionCode(encode("content=a%0Ab"))
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, errUndecodableAttributionCode)
+
+	_, err = ParseAttributionCode(strings.Repeat("a", maxAttributionCodeLength+1))
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, errUndecodableAttributionCode)
+}
+
+func TestAttributionPolicy(t *testing.T) {
+	rtamo := &AttributionCode{Content: "rta:abc"}
+	other := &AttributionCode{Content: "other"}
+
+	policy := AttributionPolicy{ContentPrefix: "rta:", RequireAllowedReferrer: true}
+	assert.True(t, policy.Allows(rtamo, "https://www.mozilla.org/", defaultTrustedSites))
+	assert.False(t, policy.Allows(rtamo, "https://example.com/", defaultTrustedSites))
+	assert.False(t, policy.Allows(rtamo, "", defaultTrustedSites))
+	assert.True(t, policy.Allows(other, "", defaultTrustedSites))
+
+	policy = AttributionPolicy{ContentPrefix: "rta:", Disallow: true}
+	assert.False(t, policy.Allows(rtamo, "https://www.mozilla.org/", defaultTrustedSites))
+	assert.True(t, policy.Allows(other, "", defaultTrustedSites))
+}
@@ -0,0 +1,223 @@
+package bouncer
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+)
+
+// maxBatchSize is the maximum number of items in a batch resolution request.
+const maxBatchSize = 500
+
+// Errors reported for the items of a batch.
+const (
+	batchErrMissingProduct = "missing product"
+	batchErrNotFound       = "not found"
+	batchErrInternal       = "internal error"
+)
+
+// ResolveBatch resolves many items at once, with the same rules as regular
+// requests, except attribution, rollouts and experiments: items have no
+// client to bucket, so an alias always resolves to the product it points to.
+// referer and userAgent are the headers of the batch request; userAgent is
+// used for the items without a user agent.
+//
+// The database is queried once per table for the whole batch, instead of
+// once per table for each item.
+func (r *Resolver) ResolveBatch(items []BatchItem, referer, userAgent string, pinHTTPS bool) ([]BatchResult, error) {
+	requests := make([]*BouncerParams, len(items))
+	products := make([]string, len(items))
+	oses := make([]string, len(items))
+	overrides := make([]string, len(items))
+	for i, item := range items {
+		reqParams := &BouncerParams{
+			OS:        strings.TrimSpace(strings.ToLower(item.OS)),
+			Product:   strings.TrimSpace(strings.ToLower(item.Product)),
+			Lang:      item.Lang,
+			Referer:   referer,
+			UserAgent: item.UserAgent,
+		}
+		if reqParams.OS == "" {
+			reqParams.OS = defaultOS
+		}
+		if reqParams.Lang == "" {
+			reqParams.Lang = defaultLang
+		}
+		if reqParams.UserAgent == "" {
+			reqParams.UserAgent = userAgent
+		}
+		requests[i] = reqParams
+		products[i], oses[i], overrides[i] = r.overrideProduct(reqParams)
+	}
+
+	lookup, err := newBatchLookup(r.db, products, oses)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]BatchResult, len(items))
+	for i, reqParams := range requests {
+		if reqParams.Product == "" {
+			results[i].Error = batchErrMissingProduct
+			continue
+		}
+
+		location, err := r.resolveBatchLocation(lookup, pinHTTPS, reqParams.Lang, oses[i], products[i])
+		switch {
+		case err != nil:
+			log.Printf("Could not resolve %s for %s (%s): %v", products[i], oses[i], reqParams.Lang, err)
+			results[i].Error = batchErrInternal
+			continue
+		case location == nil:
+			results[i].Error = batchErrNotFound
+			continue
+		}
+
+		if err := validateRedirectURL(location.URL, r.redirectHosts()); err != nil {
+			metrics.Add("redirect_rejected", 1)
+			log.Printf("Refusing to return %q: %v", location.URL, err)
+			results[i].Error = batchErrInternal
+			continue
+		}
+
+		results[i].Resolution = &Resolution{
+			URL:          location.URL,
+			Product:      location.Product,
+			OS:           oses[i],
+			Lang:         reqParams.Lang,
+			SSLOnly:      location.SSLOnly,
+			Override:     overrides[i],
+			LocationID:   location.ID,
+			LocationPath: location.Path,
+		}
+	}
+	return results, nil
+}
+
+// resolveBatchLocation is the ResolveLocation of batches.
+func (r *Resolver) resolveBatchLocation(lookup *batchLookup, pinHTTPS bool, lang, os, product string) (*Location, error) {
+	if related, ok := lookup.aliases[strings.ToLower(product)]; ok {
+		product = related
+	}
+
+	osID, ok := lookup.osIDs[strings.ToLower(os)]
+	if !ok {
+		return nil, nil
+	}
+
+	productLang, ok := lookup.productForLanguage(product, lang)
+	if !ok {
+		return nil, nil
+	}
+
+	locationPath, ok := lookup.locations[[2]string{productLang.ID, osID}]
+	if !ok {
+		return nil, nil
+	}
+
+	location := &Location{
+		ID:      locationPath.ID,
+		Product: product,
+		SSLOnly: productLang.SSLOnly,
+		Path:    locationPath.Path,
+	}
+	var err error
+	location.URL, err = r.locationURL(lookup.baseURLsFor, pinHTTPS, lang, product, productLang.SSLOnly, locationPath.Path)
+	if err != nil {
+		return nil, err
+	}
+	return location, nil
+}
+
+// batchLookup holds the rows needed to resolve a batch.
+type batchLookup struct {
+	// aliases and osIDs are keyed by lowercased name.
+	aliases map[string]string
+	osIDs   map[string]string
+	// products are keyed by lowercased name.
+	products map[string][]ProductLanguage
+	// locations are keyed by product ID and OS ID.
+	locations map[[2]string]ProductLocation
+	baseURLs  []ProductBaseURLs
+}
+
+func newBatchLookup(db *DB, products, oses []string) (*batchLookup, error) {
+	aliases, err := db.AliasesFor(products)
+	if err != nil {
+		return nil, err
+	}
+
+	osIDs, err := db.OSIDs(oses)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(products))
+	for _, product := range products {
+		if related, ok := aliases[strings.ToLower(product)]; ok {
+			product = related
+		}
+		names = append(names, product)
+	}
+	productLangs, err := db.ProductLanguages(names)
+	if err != nil {
+		return nil, err
+	}
+
+	lookup := &batchLookup{
+		aliases:   aliases,
+		osIDs:     osIDs,
+		products:  map[string][]ProductLanguage{},
+		locations: map[[2]string]ProductLocation{},
+	}
+	productIDs := []string{}
+	for _, productLang := range productLangs {
+		key := strings.ToLower(productLang.Name)
+		lookup.products[key] = append(lookup.products[key], productLang)
+		productIDs = append(productIDs, productLang.ID)
+	}
+
+	locations, err := db.LocationsFor(productIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, location := range locations {
+		key := [2]string{location.ProductID, location.OSID}
+		if _, ok := lookup.locations[key]; !ok {
+			lookup.locations[key] = location
+		}
+	}
+
+	lookup.baseURLs, err = db.AllBaseURLs()
+	if err != nil {
+		return nil, err
+	}
+	return lookup, nil
+}
+
+// productForLanguage matches DB.ProductForLanguage.
+func (l *batchLookup) productForLanguage(product, lang string) (ProductLanguage, bool) {
+	for _, productLang := range l.products[strings.ToLower(product)] {
+		if productLang.Language == "" || strings.EqualFold(productLang.Language, lang) {
+			return productLang, true
+		}
+	}
+	return ProductLanguage{}, false
+}
+
+// baseURLsFor matches DB.BaseURLsFor.
+func (l *batchLookup) baseURLsFor(product string) (http, https string, err error) {
+	var match *ProductBaseURLs
+	for i, mapping := range l.baseURLs {
+		if !strings.HasPrefix(strings.ToLower(product), strings.ToLower(mapping.Prefix)) {
+			continue
+		}
+		if match == nil || len(mapping.Prefix) > len(match.Prefix) {
+			match = &l.baseURLs[i]
+		}
+	}
+	if match == nil {
+		return "", "", sql.ErrNoRows
+	}
+	return match.HTTP, match.HTTPS, nil
+}
@@ -0,0 +1,148 @@
+package bouncer
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveBatchMatchesResolve(t *testing.T) {
+	esr115UserAgent := "Mozilla/5.0 (Windows NT 6.1; Win64; x64; rv:109.0) Gecko/20100101 Firefox/115.0"
+	items := []BatchItem{
+		{Product: "firefox-latest", OS: "osx", Lang: "en-US"},
+		{Product: "Firefox-Latest", OS: "WIN64", Lang: "en-US"},
+		{Product: "firefox-latest-ssl", OS: "win", Lang: "en-GB"},
+		{Product: "firefox", OS: "win", Lang: "fr"},
+		{Product: "firefox-latest", OS: "unknown-os", Lang: "en-US"},
+		{Product: "firefox-beta-latest-ssl", OS: "win64", Lang: "en-US", UserAgent: esr115UserAgent},
+		{Product: "firefox-nightly-latest-ssl", OS: "win", Lang: "en-US", UserAgent: "NSIS InetBgDL (Mozilla)"},
+		{Product: "thunderbird-131.0.1-ssl", OS: "win", Lang: "en-US"},
+		{Product: "firefox-store-latest-ssl", OS: "win64", Lang: "de"},
+		{Product: "unknown-product", OS: "win", Lang: "en-US"},
+		{Product: "firefox-latest", Lang: "en-US"},
+		// Names and languages are not patterns.
+		{Product: "firefox%", OS: "win", Lang: "en-US"},
+		{Product: "firefox_ssl", OS: "win", Lang: "en-US"},
+		{Product: "firefox", OS: "win", Lang: "en-%"},
+	}
+
+	// Duplicate locations resolve to the first one.
+	_, err := testDB.Exec("INSERT INTO mirror_locations (product_id, os_id, path) VALUES (1, 2, '/firefox/duplicate.dmg')")
+	assert.NoError(t, err)
+	defer func() {
+		_, err := testDB.Exec("DELETE FROM mirror_locations WHERE path = '/firefox/duplicate.dmg'")
+		assert.NoError(t, err)
+	}()
+
+	results, err := bouncerHandler.ResolveBatch(items, "", "", false)
+	assert.NoError(t, err)
+	assert.Len(t, results, len(items))
+	assert.Equal(t, "http://download.cdn.mozilla.net/pub/firefox/releases/39.0/mac/en-US/Firefox%2039.0.dmg", results[0].URL)
+
+	for i, item := range items {
+		reqParams := &BouncerParams{
+			Product:   strings.ToLower(item.Product),
+			OS:        strings.ToLower(item.OS),
+			Lang:      item.Lang,
+			UserAgent: item.UserAgent,
+		}
+		if reqParams.OS == "" {
+			reqParams.OS = defaultOS
+		}
+		expected, err := bouncerHandler.Resolve(reqParams, nil, false)
+		assert.NoError(t, err)
+
+		if expected == nil {
+			assert.Equal(t, batchErrNotFound, results[i].Error, item)
+			assert.Nil(t, results[i].Resolution, item)
+			continue
+		}
+		assert.Empty(t, results[i].Error, item)
+		assert.Equal(t, expected, results[i].Resolution, item)
+	}
+}
+
+func TestResolveBatchErrors(t *testing.T) {
+	results, err := bouncerHandler.ResolveBatch([]BatchItem{
+		{OS: "win", Lang: "en-US"},
+		// Points to an unsafe path.
+		{Product: "Firefox-127.0b9", OS: "osx", Lang: "../../../evil"},
+		// Points to an absolute location that isn't allowed.
+		{Product: "firefox-store-latest-ssl", OS: "win", Lang: "en-US"},
+		{Product: "firefox-latest", OS: "win", Lang: "en-US"},
+	}, "", "", false)
+	assert.NoError(t, err)
+	assert.Equal(t, batchErrMissingProduct, results[0].Error)
+	assert.Equal(t, batchErrInternal, results[1].Error)
+	assert.Equal(t, batchErrInternal, results[2].Error)
+	assert.Empty(t, results[3].Error)
+}
+
+func TestResolveBatchUserAgent(t *testing.T) {
+	esr115UserAgent := "Mozilla/5.0 (Windows NT 6.1; Win64; x64; rv:109.0) Gecko/20100101 Firefox/115.0"
+	items := []BatchItem{
+		{Product: "firefox-latest-ssl", OS: "win", Lang: "en-US"},
+		{Product: "firefox-latest-ssl", OS: "win", Lang: "en-US", UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64)"},
+	}
+
+	// The user agent of the batch request applies to items without one.
+	results, err := bouncerHandler.ResolveBatch(items, "", esr115UserAgent, false)
+	assert.NoError(t, err)
+	assert.Equal(t, overrideESR115, results[0].Override)
+	assert.Empty(t, results[1].Override)
+
+	// Trusted sites are exempted from the ESR override.
+	results, err = bouncerHandler.ResolveBatch(items, "https://www.mozilla.org/", esr115UserAgent, false)
+	assert.NoError(t, err)
+	assert.Empty(t, results[0].Override)
+}
+
+func TestBatchLookupBaseURLsFor(t *testing.T) {
+	lookup := &batchLookup{
+		baseURLs: []ProductBaseURLs{
+			{Prefix: "Thunderbird", HTTP: "tb", HTTPS: "tb-ssl"},
+			{Prefix: "Thunderbird-beta", HTTP: "tb-beta", HTTPS: "tb-beta-ssl"},
+		},
+	}
+
+	http, https, err := lookup.baseURLsFor("thunderbird-beta-latest")
+	assert.NoError(t, err)
+	assert.Equal(t, "tb-beta", http)
+	assert.Equal(t, "tb-beta-ssl", https)
+
+	http, _, err = lookup.baseURLsFor("Thunderbird-131.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "tb", http)
+
+	_, _, err = lookup.baseURLsFor("Firefox")
+	assert.Error(t, err)
+}
+
+func TestAPIHandlerResolveBatch(t *testing.T) {
+	h := NewAPIHandler(bouncerHandler, 0)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "http://test/api/v1/resolve", strings.NewReader(`{"items":[{"product":"firefox-latest","os":"osx","lang":"en-US"},{"product":"unknown-product"}]}`))
+	req.Header.Set("Origin", "https://www.mozilla.org")
+	h.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "https://www.mozilla.org", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, `{"items":[{"url":"http://download.cdn.mozilla.net/pub/firefox/releases/39.0/mac/en-US/Firefox%2039.0.dmg","product":"Firefox","os":"osx","lang":"en-US","ssl_only":false,"attribution":false},{"error":"not found"}]}`+"\n", w.Body.String())
+
+	for _, body := range []string{"", "{", `{"items":[]}`, `{"items":[` + strings.Repeat(`{},`, maxBatchSize) + `{}]}`} {
+		w = httptest.NewRecorder()
+		req = httptest.NewRequest("POST", "http://test/api/v1/resolve", strings.NewReader(body))
+		h.ServeHTTP(w, req)
+		assert.Equal(t, 400, w.Code, body)
+	}
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("OPTIONS", "http://test/api/v1/resolve", nil)
+	req.Header.Set("Origin", "https://www.mozilla.org")
+	h.ServeHTTP(w, req)
+	assert.Equal(t, 204, w.Code)
+	assert.Equal(t, "POST", w.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "Content-Type", w.Header().Get("Access-Control-Allow-Headers"))
+}
@@ -0,0 +1,109 @@
+// Package bouncer resolves requests for a product, OS and language to download
+// URLs, and serves them over HTTP. It is the library behind the go-bouncer
+// command, for services that want to embed the same behaviour.
+package bouncer
+
+import (
+	"net/http"
+	"time"
+)
+
+// Options configures a Resolver and the handlers built on top of it.
+type Options struct {
+	// CacheTime is the max-age of cacheable responses.
+	CacheTime time.Duration
+	// PinHTTPSHeaderName is the name of a header that, when set to https,
+	// makes bouncer return https URLs, e.g. X-Forwarded-Proto.
+	PinHTTPSHeaderName string
+	// PinnedBaseURLHttp and PinnedBaseURLHttps are the default base URLs of
+	// locations, without scheme, e.g. download.cdn.mozilla.net/pub
+	PinnedBaseURLHttp  string
+	PinnedBaseURLHttps string
+	// StubRootURL is the URL of the stub attribution service.
+	StubRootURL string
+	// StubBackends are the stub attribution backends. When empty, a default
+	// backend is derived from StubRootURL.
+	StubBackends []StubBackend
+
+	// AbsoluteLocationHosts lists the hosts that locations are allowed to
+	// point to when they hold an absolute URL instead of a path.
+	AbsoluteLocationHosts []string
+	// RespectGPC and RespectDNT make bouncer honour the corresponding privacy
+	// signals: requests are not attributed and their referer and user agent
+	// are not logged.
+	RespectGPC bool
+	RespectDNT bool
+	// AttributionSigVerification is one of the AttributionSig* modes. An
+	// empty value is the same as AttributionSigOff.
+	AttributionSigVerification string
+	// AttributionKeys are the HMAC keys used to verify attribution signatures.
+	AttributionKeys [][]byte
+	// AttributionPolicies are the rules applied to decoded attribution codes.
+	// defaultAttributionPolicies are used when nil.
+	AttributionPolicies []AttributionPolicy
+	// TrustedSites is the registry of first-party sites. defaultTrustedSites
+	// are used when nil.
+	TrustedSites TrustedSites
+	// RedirectAllowedHosts lists the hosts that bouncer may redirect to, in
+	// addition to the hosts of the pinned base URLs, the stub attribution
+	// service and AbsoluteLocationHosts.
+	RedirectAllowedHosts []string
+	// KindRules derive the paths of the resources other than installers,
+	// built with NewKindRule. defaultKindRules are used when nil.
+	KindRules []KindRule
+	// DigestHeaders adds the Repr-Digest and Digest headers to redirects to
+	// builds with a known SHA-256.
+	DigestHeaders bool
+	// RolloutKeyHeader is the name of a header holding a stable key of the
+	// client, e.g. set by a proxy, that clients are bucketed by for
+	// rollouts. Clients without it are bucketed by their IP address, from
+	// X-Forwarded-For behind a proxy, and user agent.
+	RolloutKeyHeader string
+}
+
+// Resolver resolves requests to download locations. It applies the
+// attribution, override and kind rules, and looks up the catalog in the
+// database.
+type Resolver struct {
+	db *DB
+
+	Options
+
+	// StubHealth tracks the health of the stub attribution backends. Requests
+	// for an unhealthy backend are served the direct installer instead.
+	StubHealth *StubHealthChecker
+}
+
+// NewResolver returns a Resolver using the catalog in db.
+func NewResolver(db *DB, opts Options) *Resolver {
+	return &Resolver{db: db, Options: opts}
+}
+
+// NewBouncerHandler returns a BouncerHandler using the catalog in db.
+func NewBouncerHandler(db *DB, opts Options) *BouncerHandler {
+	return &BouncerHandler{Resolver: *NewResolver(db, opts)}
+}
+
+// NewHandler returns an http.Handler serving downloads (/, /download/... and
+// /latest/...) and the JSON API (/api/v1/...), like the go-bouncer command.
+func NewHandler(db *DB, opts Options) http.Handler {
+	mux := http.NewServeMux()
+	NewBouncerHandler(db, opts).RegisterRoutes(mux)
+	return mux
+}
+
+// RegisterRoutes registers the download routes and the JSON API on mux.
+func (b *BouncerHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.Handle("/api/v1/", NewAPIHandler(b, b.CacheTime))
+	mux.HandleFunc("GET /download/{product}/{os}/{lang}", b.ServeDownloadPath)
+	mux.HandleFunc("GET /latest/{channel}/{os}", b.ServeLatestPath)
+	mux.Handle("/", b)
+}
+
+// StartStubHealthChecker checks the health of the stub attribution backends
+// every interval, in the background. Requests for an unhealthy backend are
+// then served the direct installer.
+func (b *BouncerHandler) StartStubHealthChecker(interval, timeout time.Duration) {
+	b.StubHealth = NewStubHealthChecker(b.stubBackends(), interval, timeout)
+	b.StubHealth.Start()
+}
@@ -0,0 +1,56 @@
+package bouncer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewHandler(t *testing.T) {
+	h := NewHandler(bouncerHandler.db, Options{
+		PinnedBaseURLHttp:  "download.cdn.mozilla.net/pub",
+		PinnedBaseURLHttps: "download-installer.cdn.mozilla.net/pub",
+	})
+
+	for _, url := range []string{
+		"http://test/?product=firefox-latest&os=osx&lang=en-US",
+		"http://test/download/firefox-latest/osx/en-US",
+	} {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", url, nil)
+		assert.NoError(t, err)
+
+		h.ServeHTTP(w, req)
+		assert.Equal(t, 302, w.Code, "url: %v", url)
+		assert.Equal(t, "http://download.cdn.mozilla.net/pub/firefox/releases/39.0/mac/en-US/Firefox%2039.0.dmg", w.Result().Header.Get("Location"), "url: %v", url)
+	}
+
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "http://test/api/v1/products/Firefox", nil)
+	assert.NoError(t, err)
+	h.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+}
+
+func TestResolverResolve(t *testing.T) {
+	r := NewResolver(bouncerHandler.db, Options{
+		PinnedBaseURLHttp:  "download.cdn.mozilla.net/pub",
+		PinnedBaseURLHttps: "download-installer.cdn.mozilla.net/pub",
+	})
+
+	res, err := r.Resolve(&BouncerParams{
+		Product:   "firefox-stub",
+		OS:        "win",
+		Lang:      "en-US",
+		UserAgent: "Mozilla/5.0 (Windows NT 6.1; WOW64; Trident/7.0; rv:11.0) like Gecko",
+	}, nil, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://download-installer.cdn.mozilla.net/pub/firefox/releases/115.16.1esr/win64/en-US/Firefox%20Setup%20115.16.1esr.exe", res.URL)
+	assert.Equal(t, overrideESR115, res.Override)
+
+	res, err = r.Resolve(&BouncerParams{Product: "unknown-product", OS: "win", Lang: "en-US"}, nil, false)
+	assert.NoError(t, err)
+	assert.Nil(t, res)
+}
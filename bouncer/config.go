@@ -0,0 +1,65 @@
+package bouncer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Config holds the settings that are too structured to be passed as flags. It
+// is loaded from the JSON file given by --config-file.
+type Config struct {
+	// AttributionPolicies replaces defaultAttributionPolicies when set.
+	AttributionPolicies []AttributionPolicy `json:"attribution_policies"`
+	// StubBackends replaces the backend derived from --stub-root-url when set.
+	StubBackends []StubBackend `json:"stub_backends"`
+	// TrustedSites replaces defaultTrustedSites when set.
+	TrustedSites TrustedSites `json:"trusted_sites"`
+	// KindRules replaces defaultKindRules when set.
+	KindRules []KindRule `json:"kind_rules"`
+	// AdminTokens are the tokens accepted by the admin API.
+	AdminTokens []AdminToken `json:"admin_tokens"`
+}
+
+// LoadConfig reads a JSON config file. An empty path returns an empty config.
+func LoadConfig(path string) (*Config, error) {
+	config := &Config{}
+	if path == "" {
+		return config, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, err
+	}
+
+	backendNames := map[string]bool{}
+	for _, backend := range config.StubBackends {
+		if err := backend.validate(); err != nil {
+			return nil, err
+		}
+		if backendNames[backend.Name] {
+			return nil, fmt.Errorf("duplicate stub backend %s", backend.Name)
+		}
+		backendNames[backend.Name] = true
+	}
+	for _, site := range config.TrustedSites {
+		if err := site.validate(); err != nil {
+			return nil, err
+		}
+	}
+	for i := range config.KindRules {
+		if err := config.KindRules[i].validate(); err != nil {
+			return nil, err
+		}
+	}
+	for _, token := range config.AdminTokens {
+		if err := token.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
@@ -0,0 +1,125 @@
+package bouncer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfig(t *testing.T) {
+	config, err := LoadConfig("")
+	assert.NoError(t, err)
+	assert.Nil(t, config.AttributionPolicies)
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{
+		"attribution_policies": [
+			{"content_prefix": "rta:", "require_allowed_referrer": true},
+			{"content_prefix": "blocked:", "disallow": true}
+		]
+	}`), 0o600))
+
+	config, err = LoadConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []AttributionPolicy{
+		{ContentPrefix: "rta:", RequireAllowedReferrer: true},
+		{ContentPrefix: "blocked:", Disallow: true},
+	}, config.AttributionPolicies)
+
+	assert.NoError(t, os.WriteFile(path, []byte(`{
+		"stub_backends": [
+			{"name": "dmg", "root_url": "https://dmg-stub/", "oses": ["osx"], "forward_params": ["funnelcake"]}
+		]
+	}`), 0o600))
+
+	config, err = LoadConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []StubBackend{
+		{Name: "dmg", RootURL: "https://dmg-stub/", OSes: []string{"osx"}, ForwardParams: []string{"funnelcake"}},
+	}, config.StubBackends)
+
+	// Invalid stub backend.
+	assert.NoError(t, os.WriteFile(path, []byte(`{"stub_backends": [{"name": "dmg", "oses": ["osx"]}]}`), 0o600))
+	_, err = LoadConfig(path)
+	assert.Error(t, err)
+
+	// Duplicate stub backend names.
+	assert.NoError(t, os.WriteFile(path, []byte(`{
+		"stub_backends": [
+			{"name": "dmg", "root_url": "https://dmg-stub/", "oses": ["osx"]},
+			{"name": "dmg", "root_url": "https://other-stub/", "oses": ["win"]}
+		]
+	}`), 0o600))
+	_, err = LoadConfig(path)
+	assert.Error(t, err)
+
+	assert.NoError(t, os.WriteFile(path, []byte(`{
+		"trusted_sites": [
+			{"origin": "https://www.allizom.org", "policies": ["attribution", "esr-exemption"]}
+		]
+	}`), 0o600))
+
+	config, err = LoadConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, TrustedSites{
+		{Origin: "https://www.allizom.org", Policies: []string{SitePolicyAttribution, SitePolicyESRExemption}},
+	}, config.TrustedSites)
+
+	// Invalid trusted site.
+	assert.NoError(t, os.WriteFile(path, []byte(`{"trusted_sites": [{"origin": "https://www.allizom.org", "policies": ["typo"]}]}`), 0o600))
+	_, err = LoadConfig(path)
+	assert.Error(t, err)
+
+	assert.NoError(t, os.WriteFile(path, []byte(`{
+		"kind_rules": [
+			{"kind": "release-notes", "product_prefix": "Firefox", "pattern": "^/firefox/releases/([0-9.]+)/", "template": "https://www.mozilla.org/firefox/${1}/releasenotes/"}
+		]
+	}`), 0o600))
+
+	config, err = LoadConfig(path)
+	assert.NoError(t, err)
+	assert.Len(t, config.KindRules, 1)
+	assert.Equal(t, KindReleaseNotes, config.KindRules[0].Kind)
+	assert.NotNil(t, config.KindRules[0].re)
+
+	// Invalid kind rules.
+	for _, rule := range []string{
+		`{"kind": "installer", "pattern": "^", "template": "/x"}`,
+		`{"kind": "typo", "pattern": "^", "template": "/x"}`,
+		`{"kind": "signature", "pattern": "(", "template": "/x"}`,
+		`{"kind": "signature", "pattern": "^"}`,
+	} {
+		assert.NoError(t, os.WriteFile(path, []byte(`{"kind_rules": [`+rule+`]}`), 0o600))
+		_, err = LoadConfig(path)
+		assert.Error(t, err, rule)
+	}
+
+	assert.NoError(t, os.WriteFile(path, []byte(`{
+		"admin_tokens": [
+			{"name": "releng", "token_sha256": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08", "scopes": ["aliases", "audit"]}
+		]
+	}`), 0o600))
+
+	config, err = LoadConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []AdminToken{
+		{Name: "releng", TokenSHA256: "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08", Scopes: []string{AdminScopeAliases, AdminScopeAudit}},
+	}, config.AdminTokens)
+
+	// Invalid admin tokens.
+	for _, token := range []string{
+		`{"token_sha256": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"}`,
+		`{"name": "releng", "token_sha256": "test"}`,
+		`{"name": "releng", "token_sha256": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08", "scopes": ["typo"]}`,
+	} {
+		assert.NoError(t, os.WriteFile(path, []byte(`{"admin_tokens": [`+token+`]}`), 0o600))
+		_, err = LoadConfig(path)
+		assert.Error(t, err, token)
+	}
+
+	assert.NoError(t, os.WriteFile(path, []byte(`{`), 0o600))
+	_, err = LoadConfig(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,500 @@
+package bouncer
+
+import (
+	"database/sql"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// DB is a DB instance for running queries against the bouncer database
+type DB struct {
+	*sql.DB
+
+	rollouts rolloutAliases
+	baseURLs baseURLHosts
+}
+
+// baseURLHostsCacheTime is how long the hosts of the product base URL
+// mappings are cached, i.e. how long a new mapping takes to be allowed as a
+// redirect target.
+const baseURLHostsCacheTime = 10 * time.Second
+
+// baseURLHosts caches the hosts of the product base URL mappings, which
+// redirects are checked against.
+type baseURLHosts struct {
+	mu        sync.Mutex
+	hosts     []string
+	expiresAt time.Time
+}
+
+// NewDB returns a new database instance.
+func NewDB(dsn string) (*DB, error) {
+	db, err := sql.Open("mysql", dsn)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Ping()
+	if err != nil {
+		return nil, err
+	}
+
+	return &DB{
+		DB: db,
+	}, nil
+}
+
+// AliasFor returns the alias for a product
+//
+// For example firefox-latest will resolve to the latest version of firefox.
+// Scheduled switches apply as soon as they are due.
+func (d *DB) AliasFor(product string) (related string, err error) {
+	related, err = aliasProductAt(d, product, time.Now())
+	if err != nil {
+		return "", err
+	}
+	if related == "" {
+		return product, nil
+	}
+	return related, nil
+}
+
+// OSID returns the id of an operation system, by name
+func (d *DB) OSID(name string) (id string, err error) {
+	err = d.QueryRow(
+		"SELECT id FROM mirror_os WHERE name = ?",
+		name).Scan(&id)
+
+	return
+}
+
+// ProductForLanguage returns the product ID given a product name and language.
+// Like ProductLanguages, names and languages are matched exactly and the
+// first product by ID wins.
+func (d *DB) ProductForLanguage(product, lang string) (productID string, sslOnly bool, err error) {
+	sslInt := 0
+	err = d.QueryRow(
+		`SELECT prod.id, prod.ssl_only FROM mirror_products AS prod
+		LEFT JOIN mirror_product_langs AS langs ON (prod.id = langs.product_id)
+		WHERE prod.name = ?
+		AND (langs.language = ? OR langs.language IS NULL)
+		ORDER BY prod.id
+		LIMIT 1`,
+		product, lang).Scan(&productID, &sslInt)
+
+	if sslInt == 1 {
+		sslOnly = true
+	} else {
+		sslOnly = false
+	}
+	return
+}
+
+// Location returns the path of the product/os combination. Like
+// LocationsFor, the first location by ID wins.
+func (d *DB) Location(productID, osID string) (id, path string, err error) {
+	err = d.QueryRow(
+		`SELECT id, path FROM mirror_locations
+			WHERE product_id = ? AND os_id = ?
+			ORDER BY id
+			LIMIT 1`,
+		productID, osID).Scan(&id, &path)
+
+	return
+}
+
+// BaseURLsFor returns the base URLs configured for a product, by name.
+//
+// Mappings are keyed by product name prefix and the longest matching prefix
+// wins. Prefixes are literal: _ and % are not wildcards. sql.ErrNoRows is
+// returned when no mapping exists. A mapping can leave one of the base URLs
+// empty, in which case the caller should fall back to the global value.
+func (d *DB) BaseURLsFor(product string) (http, https string, err error) {
+	err = d.QueryRow(
+		`SELECT baseurl_http, baseurl_https FROM mirror_product_baseurls
+			WHERE LEFT(?, CHAR_LENGTH(prefix)) = prefix
+			ORDER BY CHAR_LENGTH(prefix) DESC
+			LIMIT 1`,
+		product).Scan(&http, &https)
+
+	return
+}
+
+// LocationMetadata describes the build behind a location.
+type LocationMetadata struct {
+	// SHA256 is the hex-encoded SHA-256 of the build.
+	SHA256  string
+	Size    int64
+	Version string
+	// SignaturePath is the path of the detached signature of the build. Like
+	// location paths, it can contain :lang.
+	SignaturePath string
+}
+
+// MetadataFor returns the metadata of a location for a language. Metadata for
+// the language takes precedence over metadata for all languages (stored with
+// an empty language). sql.ErrNoRows is returned when there is none.
+func (d *DB) MetadataFor(locationID, lang string) (*LocationMetadata, error) {
+	metadata := &LocationMetadata{}
+	err := d.QueryRow(
+		`SELECT sha256, size, version, signature_path FROM mirror_location_metadata
+			WHERE location_id = ? AND language IN (?, '')
+			ORDER BY language DESC
+			LIMIT 1`,
+		locationID, lang).Scan(&metadata.SHA256, &metadata.Size, &metadata.Version, &metadata.SignaturePath)
+	if err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// Aliases returns a page of aliases, ordered by alias. Like AliasFor, it
+// includes the due switches, but aliases created by a switch only appear
+// once it is promoted.
+func (d *DB) Aliases(limit, offset int) ([]Alias, error) {
+	rows, err := d.Query(
+		`SELECT alias, related_product FROM mirror_aliases
+			ORDER BY alias
+			LIMIT ? OFFSET ?`,
+		limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	aliases := []Alias{}
+	for rows.Next() {
+		var alias Alias
+		if err := rows.Scan(&alias.Alias, &alias.Product); err != nil {
+			return nil, err
+		}
+		aliases = append(aliases, alias)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(aliases))
+	for i, alias := range aliases {
+		names[i] = alias.Alias
+	}
+	due, err := dueAliasSwitches(d, names)
+	if err != nil {
+		return nil, err
+	}
+	for i, alias := range aliases {
+		if product, ok := due[strings.ToLower(alias.Alias)]; ok {
+			aliases[i].Product = product
+		}
+	}
+	return aliases, nil
+}
+
+// Products returns a page of products, ordered by name.
+func (d *DB) Products(limit, offset int) ([]*Product, error) {
+	rows, err := d.Query(
+		`SELECT id, name, ssl_only FROM mirror_products
+			ORDER BY name
+			LIMIT ? OFFSET ?`,
+		limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := []*Product{}
+	for rows.Next() {
+		product := &Product{}
+		sslInt := 0
+		if err := rows.Scan(&product.ID, &product.Name, &sslInt); err != nil {
+			return nil, err
+		}
+		product.SSLOnly = sslInt == 1
+		products = append(products, product)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return products, d.fillProductDetails(products)
+}
+
+// ProductByName returns a product, by name.
+func (d *DB) ProductByName(name string) (*Product, error) {
+	product := &Product{}
+	sslInt := 0
+	err := d.QueryRow(
+		"SELECT id, name, ssl_only FROM mirror_products WHERE name = ?",
+		name).Scan(&product.ID, &product.Name, &sslInt)
+	if err != nil {
+		return nil, err
+	}
+	product.SSLOnly = sslInt == 1
+
+	return product, d.fillProductDetails([]*Product{product})
+}
+
+// fillProductDetails sets the languages of the products, and the OSes for which
+// they have a location.
+func (d *DB) fillProductDetails(products []*Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	byID := map[string]*Product{}
+	ids := make([]string, 0, len(products))
+	for _, product := range products {
+		product.Languages = []string{}
+		product.OSes = []string{}
+		byID[product.ID] = product
+		ids = append(ids, product.ID)
+	}
+
+	rows, err := d.Query(
+		`SELECT product_id, language FROM mirror_product_langs
+			WHERE product_id IN (`+placeholders(len(ids))+`)
+			ORDER BY language`,
+		args(ids)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var productID, language string
+		if err := rows.Scan(&productID, &language); err != nil {
+			return err
+		}
+		byID[productID].Languages = append(byID[productID].Languages, language)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = d.Query(
+		`SELECT DISTINCT loc.product_id, os.name FROM mirror_locations AS loc
+			JOIN mirror_os AS os ON (loc.os_id = os.id)
+			WHERE loc.product_id IN (`+placeholders(len(ids))+`)
+			ORDER BY os.name`,
+		args(ids)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var productID, os string
+		if err := rows.Scan(&productID, &os); err != nil {
+			return err
+		}
+		byID[productID].OSes = append(byID[productID].OSes, os)
+	}
+	return rows.Err()
+}
+
+// AliasesFor returns the products that aliases point to, keyed by lowercased
+// alias. Unknown aliases are omitted.
+func (d *DB) AliasesFor(aliases []string) (map[string]string, error) {
+	related := map[string]string{}
+	if len(aliases) == 0 {
+		return related, nil
+	}
+
+	rows, err := d.Query(
+		`SELECT alias, related_product FROM mirror_aliases
+			WHERE alias IN (`+placeholders(len(aliases))+`)`,
+		args(aliases)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var alias, product string
+		if err := rows.Scan(&alias, &product); err != nil {
+			return nil, err
+		}
+		related[strings.ToLower(alias)] = product
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	due, err := dueAliasSwitches(d, aliases)
+	if err != nil {
+		return nil, err
+	}
+	for alias, product := range due {
+		related[alias] = product
+	}
+	return related, nil
+}
+
+// OSIDs returns the ids of operating systems, keyed by lowercased name.
+// Unknown operating systems are omitted.
+func (d *DB) OSIDs(names []string) (map[string]string, error) {
+	ids := map[string]string{}
+	if len(names) == 0 {
+		return ids, nil
+	}
+
+	rows, err := d.Query(
+		`SELECT id, name FROM mirror_os
+			WHERE name IN (`+placeholders(len(names))+`)`,
+		args(names)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		ids[strings.ToLower(name)] = id
+	}
+	return ids, rows.Err()
+}
+
+// ProductLanguage is a product along with one of its languages. Language is
+// empty when the product is available in all languages.
+type ProductLanguage struct {
+	ID       string
+	Name     string
+	SSLOnly  bool
+	Language string
+}
+
+// ProductLanguages returns the products with the given names, with one entry
+// per language.
+func (d *DB) ProductLanguages(names []string) ([]ProductLanguage, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	rows, err := d.Query(
+		`SELECT prod.id, prod.name, prod.ssl_only, langs.language FROM mirror_products AS prod
+			LEFT JOIN mirror_product_langs AS langs ON (prod.id = langs.product_id)
+			WHERE prod.name IN (`+placeholders(len(names))+`)
+			ORDER BY prod.id`,
+		args(names)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := []ProductLanguage{}
+	for rows.Next() {
+		var product ProductLanguage
+		var language sql.NullString
+		sslInt := 0
+		if err := rows.Scan(&product.ID, &product.Name, &sslInt, &language); err != nil {
+			return nil, err
+		}
+		product.SSLOnly = sslInt == 1
+		product.Language = language.String
+		products = append(products, product)
+	}
+	return products, rows.Err()
+}
+
+// ProductLocation is the path of a product for an operating system.
+type ProductLocation struct {
+	ID        string
+	ProductID string
+	OSID      string
+	Path      string
+}
+
+// LocationsFor returns the locations of the given products.
+func (d *DB) LocationsFor(productIDs []string) ([]ProductLocation, error) {
+	if len(productIDs) == 0 {
+		return nil, nil
+	}
+
+	rows, err := d.Query(
+		`SELECT id, product_id, os_id, path FROM mirror_locations
+			WHERE product_id IN (`+placeholders(len(productIDs))+`)
+			ORDER BY id`,
+		args(productIDs)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	locations := []ProductLocation{}
+	for rows.Next() {
+		var location ProductLocation
+		if err := rows.Scan(&location.ID, &location.ProductID, &location.OSID, &location.Path); err != nil {
+			return nil, err
+		}
+		locations = append(locations, location)
+	}
+	return locations, rows.Err()
+}
+
+// ProductBaseURLs are the base URLs of the products whose name starts with
+// Prefix.
+type ProductBaseURLs struct {
+	Prefix string
+	HTTP   string
+	HTTPS  string
+}
+
+// AllBaseURLs returns all the product base URL mappings.
+func (d *DB) AllBaseURLs() ([]ProductBaseURLs, error) {
+	rows, err := d.Query(`SELECT prefix, baseurl_http, baseurl_https FROM mirror_product_baseurls`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mappings := []ProductBaseURLs{}
+	for rows.Next() {
+		var mapping ProductBaseURLs
+		if err := rows.Scan(&mapping.Prefix, &mapping.HTTP, &mapping.HTTPS); err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, mapping)
+	}
+	return mappings, rows.Err()
+}
+
+// mappedHosts returns the hosts of the product base URL mappings, as of at
+// most baseURLHostsCacheTime ago. When they can't be reloaded, the previous
+// ones are returned along with the error.
+func (d *DB) mappedHosts() ([]string, error) {
+	d.baseURLs.mu.Lock()
+	defer d.baseURLs.mu.Unlock()
+
+	if time.Now().Before(d.baseURLs.expiresAt) {
+		return d.baseURLs.hosts, nil
+	}
+	mappings, err := d.AllBaseURLs()
+	if err != nil {
+		return d.baseURLs.hosts, err
+	}
+	hosts := make([]string, 0, 2*len(mappings))
+	for _, mapping := range mappings {
+		hosts = append(hosts, baseURLHost(mapping.HTTP), baseURLHost(mapping.HTTPS))
+	}
+	d.baseURLs.hosts = hosts
+	d.baseURLs.expiresAt = time.Now().Add(baseURLHostsCacheTime)
+	return hosts, nil
+}
+
+// placeholders returns n comma-separated placeholders, for IN clauses.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+func args(values []string) []any {
+	args := make([]any, len(values))
+	for i, value := range values {
+		args[i] = value
+	}
+	return args
+}
@@ -0,0 +1,145 @@
+package bouncer
+
+import (
+	"database/sql"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testDB *DB
+
+func TestMain(m *testing.M) {
+	var err error
+	testDB, err = NewDB("root@tcp(127.0.0.1:3306)/bouncer_test")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer testDB.Close()
+	os.Exit(m.Run())
+}
+
+func TestAliasFor(t *testing.T) {
+	res, err := testDB.AliasFor("firefox-latest")
+	assert.NoError(t, err)
+	assert.Equal(t, "Firefox", res)
+
+	res, err = testDB.AliasFor("firefox-123")
+	assert.NoError(t, err)
+	// When the alias is not found, we return the product.
+	assert.Equal(t, "firefox-123", res)
+}
+
+func TestOSID(t *testing.T) {
+	res, err := testDB.OSID("win64")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", res)
+}
+
+func TestProductForLanguage(t *testing.T) {
+	res, sslOnly, err := testDB.ProductForLanguage("Firefox", "en-US")
+	assert.NoError(t, err)
+	assert.False(t, sslOnly)
+	assert.Equal(t, "1", res)
+
+	res, sslOnly, err = testDB.ProductForLanguage("Firefox-SSL", "en-US")
+	assert.NoError(t, err)
+	assert.True(t, sslOnly)
+	assert.Equal(t, "2", res)
+
+	// Names are not patterns.
+	_, _, err = testDB.ProductForLanguage("Firefox%", "en-US")
+	assert.Equal(t, sql.ErrNoRows, err)
+}
+
+func TestLocation(t *testing.T) {
+	// We need some IDs before we can invoke `Location()`.
+	productID, _, _ := testDB.ProductForLanguage("Firefox", "en-US")
+	osID, _ := testDB.OSID("win64")
+
+	id, path, err := testDB.Location(productID, osID)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", id)
+	assert.Equal(t, "/firefox/releases/39.0/win64/:lang/Firefox%20Setup%2039.0.exe", path)
+
+	// Unknown location
+	_, _, err = testDB.Location("some-product-id", osID)
+	assert.Error(t, err, sql.ErrNoRows)
+}
+
+func TestBaseURLsFor(t *testing.T) {
+	http, https, err := testDB.BaseURLsFor("Thunderbird-131.0.1-SSL")
+	assert.NoError(t, err)
+	assert.Equal(t, "download.cdn.thunderbird.net/pub", http)
+	assert.Equal(t, "download-installer.cdn.thunderbird.net/pub", https)
+
+	// No mapping for this product.
+	_, _, err = testDB.BaseURLsFor("Firefox")
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	// Prefixes are not patterns.
+	_, err = testDB.Exec("INSERT INTO mirror_product_baseurls (prefix, baseurl_http, baseurl_https) VALUES ('Fire_ox%', 'wildcard', 'wildcard')")
+	assert.NoError(t, err)
+	defer func() {
+		_, err := testDB.Exec("DELETE FROM mirror_product_baseurls WHERE prefix = 'Fire_ox%'")
+		assert.NoError(t, err)
+	}()
+	_, _, err = testDB.BaseURLsFor("Firefox-127.0")
+	assert.Equal(t, sql.ErrNoRows, err)
+}
+
+func TestAliases(t *testing.T) {
+	aliases, err := testDB.Aliases(2, 0)
+	assert.NoError(t, err)
+	assert.Len(t, aliases, 2)
+
+	next, err := testDB.Aliases(2, 2)
+	assert.NoError(t, err)
+	assert.Len(t, next, 2)
+	assert.Less(t, aliases[1].Alias, next[0].Alias)
+
+	none, err := testDB.Aliases(2, 1000)
+	assert.NoError(t, err)
+	assert.Empty(t, none)
+}
+
+func TestProductByName(t *testing.T) {
+	product, err := testDB.ProductByName("Firefox-SSL")
+	assert.NoError(t, err)
+	assert.Equal(t, "Firefox-SSL", product.Name)
+	assert.True(t, product.SSLOnly)
+	assert.Equal(t, []string{"en-GB", "en-US"}, product.Languages)
+	assert.Equal(t, []string{"osx", "win", "win64"}, product.OSes)
+
+	_, err = testDB.ProductByName("unknown-product")
+	assert.Equal(t, sql.ErrNoRows, err)
+}
+
+func TestProducts(t *testing.T) {
+	products, err := testDB.Products(1000, 0)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, products)
+	for _, product := range products {
+		assert.NotNil(t, product.Languages)
+		assert.NotNil(t, product.OSes)
+	}
+}
+
+func TestMetadataFor(t *testing.T) {
+	metadata, err := testDB.MetadataFor("6", "en-US")
+	assert.NoError(t, err)
+	assert.Equal(t, "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08", metadata.SHA256)
+	assert.Equal(t, int64(49542368), metadata.Size)
+	assert.Equal(t, "39.0", metadata.Version)
+
+	// Falls back to the metadata for all languages.
+	metadata, err = testDB.MetadataFor("6", "en-GB")
+	assert.NoError(t, err)
+	assert.Empty(t, metadata.SHA256)
+	assert.Equal(t, "39.0", metadata.Version)
+
+	_, err = testDB.MetadataFor("1", "en-US")
+	assert.Equal(t, sql.ErrNoRows, err)
+}
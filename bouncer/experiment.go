@@ -0,0 +1,226 @@
+package bouncer
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Experiment maps requests of a base product (or alias) with a funnelcake
+// param to a variant product, between StartsAt and EndsAt. Outside of that
+// window, requests get the base product again.
+type Experiment struct {
+	Product  string    `json:"product"`
+	ID       string    `json:"id"`
+	Variant  string    `json:"variant"`
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+	// TrafficPercent is the percentage of the clients of the experiment that
+	// get the variant. Zero means all of them.
+	TrafficPercent int `json:"traffic_percent,omitempty"`
+}
+
+// capped reports whether only some of the clients get the variant.
+func (e *Experiment) capped() bool {
+	return e.TrafficPercent > 0 && e.TrafficPercent < rolloutBuckets
+}
+
+// Experiments returns a page of the experiments, including the ones that
+// ended, in the order they start.
+func (d *DB) Experiments(limit, offset int) ([]Experiment, error) {
+	rows, err := d.Query(
+		`SELECT product, experiment, variant, starts_at, ends_at, traffic_percent FROM mirror_experiments
+			ORDER BY starts_at, id
+			LIMIT ? OFFSET ?`,
+		limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	experiments := []Experiment{}
+	for rows.Next() {
+		e, err := scanExperiment(rows)
+		if err != nil {
+			return nil, err
+		}
+		experiments = append(experiments, *e)
+	}
+	return experiments, rows.Err()
+}
+
+// Experiment returns an experiment of a product, or sql.ErrNoRows.
+func (d *DB) Experiment(product, id string) (*Experiment, error) {
+	return experiment(d, product, id)
+}
+
+// PutExperiment creates or updates an experiment. The base product can be a
+// product or an alias, and the variant must be a product.
+func (d *DB) PutExperiment(actor string, e *Experiment) (created bool, err error) {
+	// The database stores microseconds.
+	e.StartsAt = e.StartsAt.UTC().Truncate(time.Microsecond)
+	e.EndsAt = e.EndsAt.UTC().Truncate(time.Microsecond)
+	if !e.EndsAt.After(e.StartsAt) {
+		return false, fmt.Errorf("%w: an experiment must end after it starts", ErrInvalid)
+	}
+	if !e.EndsAt.After(time.Now()) {
+		return false, fmt.Errorf("%w: an experiment must end in the future", ErrInvalid)
+	}
+	if e.TrafficPercent < 0 || e.TrafficPercent > rolloutBuckets {
+		return false, fmt.Errorf("%w: traffic_percent must be between 1 and %d, or omitted", ErrInvalid, rolloutBuckets)
+	}
+	if strings.EqualFold(e.Product, e.Variant) {
+		return false, fmt.Errorf("%w: the variant must not be the base product", ErrInvalid)
+	}
+
+	err = d.inTx(func(tx *sql.Tx) error {
+		if _, _, err := productRow(tx, e.Product); errors.Is(err, sql.ErrNoRows) {
+			alias, err := currentAlias(tx, e.Product)
+			if err != nil {
+				return err
+			}
+			if alias == "" {
+				return fmt.Errorf("%w: unknown product %s", ErrInvalid, e.Product)
+			}
+		} else if err != nil {
+			return err
+		}
+		if _, _, err := productRow(tx, e.Variant); errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: unknown product %s", ErrInvalid, e.Variant)
+		} else if err != nil {
+			return err
+		}
+
+		var old any
+		oldExperiment, err := experiment(tx, e.Product, e.ID)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			created = true
+			_, err = tx.Exec(
+				`INSERT INTO mirror_experiments (product, experiment, variant, starts_at, ends_at, traffic_percent)
+					VALUES (?, ?, ?, ?, ?, ?)`,
+				e.Product, e.ID, e.Variant, e.StartsAt, e.EndsAt, trafficPercent(e.TrafficPercent))
+		case err == nil:
+			old = oldExperiment
+			_, err = tx.Exec(
+				`UPDATE mirror_experiments SET variant = ?, starts_at = ?, ends_at = ?, traffic_percent = ?
+					WHERE product = ? AND experiment = ?`,
+				e.Variant, e.StartsAt, e.EndsAt, trafficPercent(e.TrafficPercent), e.Product, e.ID)
+		}
+		if err != nil {
+			return err
+		}
+		return audit(tx, actor, AuditActionPut, AuditResourceExperiment, e.Product+"/"+e.ID, old, e)
+	})
+	return created, err
+}
+
+// DeleteExperiment deletes an experiment, so that its requests get the base
+// product. sql.ErrNoRows is returned when it doesn't exist.
+func (d *DB) DeleteExperiment(actor, product, id string) error {
+	return d.inTx(func(tx *sql.Tx) error {
+		old, err := experiment(tx, product, id)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec("DELETE FROM mirror_experiments WHERE product = ? AND experiment = ?", product, id); err != nil {
+			return err
+		}
+		return audit(tx, actor, AuditActionDelete, AuditResourceExperiment, product+"/"+id, old, nil)
+	})
+}
+
+// experimentProduct returns the experiment that a request of a product is
+// part of, along with the assignment of the client and the variant that it
+// gets, if any. A nil experiment is returned when the request has no
+// funnelcake param, when its product was overridden or when the experiment
+// isn't running at the time of the request.
+func (r *Resolver) experimentProduct(product, override string, reqParams *BouncerParams) (*Experiment, *ExperimentAssignment, string, error) {
+	if reqParams.Experiment == "" || override != "" {
+		return nil, nil, "", nil
+	}
+
+	at := reqParams.AsOf
+	if at.IsZero() {
+		at = time.Now()
+	}
+	e, err := experiment(r.db, product, reqParams.Experiment)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, "", nil
+	}
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if at.Before(e.StartsAt) || !at.Before(e.EndsAt) {
+		return nil, nil, "", nil
+	}
+
+	assignment := &ExperimentAssignment{ID: e.ID}
+	if e.capped() {
+		bucket := rolloutBucket(e.Product+"/"+e.ID, reqParams.RolloutKey)
+		assignment.Bucket = &bucket
+		if bucket >= e.TrafficPercent {
+			return e, assignment, "", nil
+		}
+	}
+	return e, assignment, e.Variant, nil
+}
+
+// experimentUsing returns an experiment that hasn't ended and uses name as
+// its base or variant product, or sql.ErrNoRows.
+func experimentUsing(q queryer, name string) (*Experiment, error) {
+	return queryExperiment(q,
+		`SELECT product, experiment, variant, starts_at, ends_at, traffic_percent FROM mirror_experiments
+			WHERE (product = ? OR variant = ?) AND ends_at > ?
+			LIMIT 1`,
+		name, name, time.Now().UTC())
+}
+
+func experiment(q queryer, product, id string) (*Experiment, error) {
+	return queryExperiment(q,
+		`SELECT product, experiment, variant, starts_at, ends_at, traffic_percent FROM mirror_experiments
+			WHERE product = ? AND experiment = ?`,
+		product, id)
+}
+
+func queryExperiment(q queryer, query string, args ...any) (*Experiment, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, sql.ErrNoRows
+	}
+	return scanExperiment(rows)
+}
+
+func scanExperiment(rows *sql.Rows) (*Experiment, error) {
+	var e Experiment
+	var startsAt, endsAt string
+	var percent sql.NullInt64
+	if err := rows.Scan(&e.Product, &e.ID, &e.Variant, &startsAt, &endsAt, &percent); err != nil {
+		return nil, err
+	}
+	var err error
+	if e.StartsAt, err = parseDBTime(startsAt); err != nil {
+		return nil, err
+	}
+	if e.EndsAt, err = parseDBTime(endsAt); err != nil {
+		return nil, err
+	}
+	e.TrafficPercent = int(percent.Int64)
+	return &e, nil
+}
+
+// trafficPercent stores experiments without a traffic cap as NULL.
+func trafficPercent(percent int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(percent), Valid: percent > 0}
+}
@@ -0,0 +1,183 @@
+package bouncer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mozilla-services/go-bouncer/bouncerapi"
+	"github.com/stretchr/testify/assert"
+)
+
+// insertExperiment registers an experiment at any time, unlike PutExperiment
+// which only registers experiments that end in the future.
+func insertExperiment(t *testing.T, product, id, variant string, startsAt, endsAt time.Time, percent int) {
+	_, err := testDB.Exec(
+		`INSERT INTO mirror_experiments (product, experiment, variant, starts_at, ends_at, traffic_percent)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+		product, id, variant, startsAt.UTC(), endsAt.UTC(), trafficPercent(percent))
+	assert.NoError(t, err)
+}
+
+func TestBouncerHandlerExperiment(t *testing.T) {
+	defer func() {
+		_, err := testDB.Exec("DELETE FROM mirror_experiments WHERE product = 'firefox-latest'")
+		assert.NoError(t, err)
+	}()
+	now := time.Now()
+	insertExperiment(t, "firefox-latest", "running", "Firefox-127.0", now.Add(-time.Hour), now.Add(time.Hour), 0)
+	insertExperiment(t, "firefox-latest", "ended", "Firefox-127.0", now.Add(-2*time.Hour), now.Add(-time.Hour), 0)
+	insertExperiment(t, "firefox-latest", "upcoming", "Firefox-127.0", now.Add(time.Hour), now.Add(2*time.Hour), 0)
+	insertExperiment(t, "firefox-latest", "nightly", "Firefox-nightly-latest", now.Add(-time.Hour), now.Add(time.Hour), 0)
+	insertExperiment(t, "firefox-latest", "capped", "Firefox-127.0", now.Add(-time.Hour), now.Add(time.Hour), 30)
+	insertExperiment(t, "Firefox-Latest", "stored", "Firefox-127.0", now.Add(-time.Hour), now.Add(time.Hour), 0)
+
+	h := *bouncerHandler
+	h.RolloutKeyHeader = "X-Rollout-Key"
+	h.CacheTime = 10 * time.Minute
+	request := func(url, key string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", url, nil)
+		assert.NoError(t, err)
+		req.Header.Set("X-Rollout-Key", key)
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	variant := "http://download.cdn.mozilla.net/pub/firefox/releases/127.0/mac/en-US/Firefox%20Setup%20127.0.exe"
+	base := "http://download.cdn.mozilla.net/pub/firefox/releases/39.0/mac/en-US/Firefox%2039.0.dmg"
+	for _, tc := range []struct {
+		funnelcake string
+		location   string
+	}{
+		{"running", variant},
+		{"ended", base},
+		{"upcoming", base},
+		{"unknown", base},
+		{"", base},
+		// The variant isn't available on osx.
+		{"nightly", base},
+	} {
+		w := request("http://test/?product=firefox-latest&os=osx&lang=en-US&funnelcake="+tc.funnelcake, "client")
+		assert.Equal(t, 302, w.Code, tc.funnelcake)
+		assert.Equal(t, tc.location, w.Result().Header.Get("Location"), tc.funnelcake)
+		assert.Equal(t, "max-age=600", w.Result().Header.Get("Cache-Control"), tc.funnelcake)
+	}
+	assert.NotNil(t, experimentMetrics.Get("firefox-latest/running/Firefox-127.0"))
+	assert.NotNil(t, experimentMetrics.Get("firefox-latest/nightly/Firefox"))
+
+	// Metrics use the base product of the experiment as stored.
+	request("http://test/?product=firefox-latest&os=osx&lang=en-US&funnelcake=stored", "client")
+	assert.NotNil(t, experimentMetrics.Get("Firefox-Latest/stored/Firefox-127.0"))
+	assert.Nil(t, experimentMetrics.Get("firefox-latest/stored/Firefox-127.0"))
+
+	w := request("http://test/?product=firefox-latest&os=osx&lang=en-US&funnelcake=running&print=json", "client")
+	var res Resolution
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, "Firefox-127.0", res.Product)
+	assert.Equal(t, &ExperimentAssignment{ID: "running", Variant: true}, res.Experiment)
+
+	w = request("http://test/?product=firefox-latest&os=osx&lang=en-US&funnelcake=nightly&print=json", "client")
+	res = Resolution{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, "Firefox", res.Product)
+	assert.Equal(t, &ExperimentAssignment{ID: "nightly"}, res.Experiment)
+
+	// Past resolutions use the experiments running at the time.
+	w = request(fmt.Sprintf("http://test/?product=firefox-latest&os=osx&lang=en-US&funnelcake=ended&print=yes&as_of=%s", now.Add(-90*time.Minute).UTC().Format(time.RFC3339)), "client")
+	assert.Equal(t, variant, w.Body.String())
+
+	// Overridden products are not part of experiments.
+	w = httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "http://test/?product=firefox-latest&os=win&lang=en-US&funnelcake=running&print=json", nil)
+	assert.NoError(t, err)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 6.1; WOW64; Trident/7.0; rv:11.0) like Gecko")
+	h.ServeHTTP(w, req)
+	res = Resolution{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, "esr115", res.Override)
+	assert.Nil(t, res.Experiment)
+
+	variantKey := rolloutTestKey("firefox-latest/capped", 0, 30)
+	baseKey := rolloutTestKey("firefox-latest/capped", 30, 100)
+	for _, tc := range []struct {
+		key      string
+		location string
+	}{
+		{variantKey, variant},
+		{baseKey, base},
+	} {
+		w := request("http://test/?product=firefox-latest&os=osx&lang=en-US&funnelcake=capped", tc.key)
+		assert.Equal(t, tc.location, w.Result().Header.Get("Location"))
+		assert.Equal(t, fmt.Sprintf("capped/%d", rolloutBucket("firefox-latest/capped", tc.key)), w.Result().Header.Get("X-Experiment-Bucket"))
+		assert.Equal(t, "private, max-age=600", w.Result().Header.Get("Cache-Control"))
+		assert.Equal(t, "X-Rollout-Key", w.Result().Header.Get("Vary"))
+	}
+}
+
+func TestAdminHandlerExperiment(t *testing.T) {
+	defer func() {
+		_, err := testDB.Exec("DELETE FROM mirror_experiments WHERE product = 'firefox-beta-stub'")
+		assert.NoError(t, err)
+	}()
+
+	h := newTestAdminHandler()
+	path := "/admin/v1/experiments/firefox-beta-stub/137"
+
+	for _, tc := range []struct {
+		path string
+		body string
+		code int
+	}{
+		{path, `{"variant": "Firefox-127.0", "starts_at": "2099-02-01T00:00:00Z", "ends_at": "2099-01-01T00:00:00Z"}`, 400},
+		{path, `{"variant": "Firefox-127.0", "starts_at": "2024-01-01T00:00:00Z", "ends_at": "2024-02-01T00:00:00Z"}`, 400},
+		{path, `{"variant": "Firefox-127.0", "starts_at": "2099-01-01T00:00:00Z", "ends_at": "2099-02-01T00:00:00Z", "traffic_percent": 101}`, 400},
+		{path, `{"variant": "unknown-product", "starts_at": "2099-01-01T00:00:00Z", "ends_at": "2099-02-01T00:00:00Z"}`, 400},
+		{path, `{"variant": "firefox-beta-stub", "starts_at": "2099-01-01T00:00:00Z", "ends_at": "2099-02-01T00:00:00Z"}`, 400},
+		{"/admin/v1/experiments/unknown-product/137", `{"variant": "Firefox-127.0", "starts_at": "2099-01-01T00:00:00Z", "ends_at": "2099-02-01T00:00:00Z"}`, 400},
+		{"/admin/v1/experiments/firefox-beta-stub/bad%20id", `{"variant": "Firefox-127.0", "starts_at": "2099-01-01T00:00:00Z", "ends_at": "2099-02-01T00:00:00Z"}`, 400},
+	} {
+		w := adminRequest(h, "PUT", tc.path, "releng-token", tc.body)
+		assert.Equal(t, tc.code, w.Code, tc.body)
+	}
+
+	w := adminRequest(h, "GET", path, "releng-token", "")
+	assert.Equal(t, 404, w.Code)
+
+	w = adminRequest(h, "PUT", path, "releng-token", `{"variant": "Firefox-127.0", "starts_at": "2099-01-01T00:00:00Z", "ends_at": "2099-02-01T00:00:00Z"}`)
+	assert.Equal(t, 201, w.Code)
+	w = adminRequest(h, "PUT", path, "releng-token", `{"variant": "Firefox-127.0b9", "starts_at": "2099-01-01T00:00:00Z", "ends_at": "2099-02-01T00:00:00Z", "traffic_percent": 20}`)
+	assert.Equal(t, 200, w.Code)
+	expected := `{"product": "firefox-beta-stub", "id": "137", "variant": "Firefox-127.0b9", "starts_at": "2099-01-01T00:00:00Z", "ends_at": "2099-02-01T00:00:00Z", "traffic_percent": 20}`
+	assert.JSONEq(t, expected, w.Body.String())
+	w = adminRequest(h, "GET", path, "releng-token", "")
+	assert.Equal(t, 200, w.Code)
+	assert.JSONEq(t, expected, w.Body.String())
+
+	w = adminRequest(h, "GET", "/admin/v1/experiments", "releng-token", "")
+	assert.Equal(t, 200, w.Code)
+	var page bouncerapi.Page[Experiment]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Len(t, page.Items, 1)
+	assert.Equal(t, "Firefox-127.0b9", page.Items[0].Variant)
+
+	entries, err := testDB.AuditLog(1, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, AuditResourceExperiment, entries[0].Resource)
+	assert.Equal(t, "firefox-beta-stub/137", entries[0].Key)
+	assert.JSONEq(t, `{"product": "firefox-beta-stub", "id": "137", "variant": "Firefox-127.0", "starts_at": "2099-01-01T00:00:00Z", "ends_at": "2099-02-01T00:00:00Z"}`, string(entries[0].Old))
+
+	// Products of an experiment that hasn't ended can't be deleted.
+	w = adminRequest(h, "DELETE", "/admin/v1/products/Firefox-127.0b9", "releng-token", "")
+	assert.Equal(t, 409, w.Code)
+
+	w = adminRequest(h, "DELETE", path, "auditor-token", "")
+	assert.Equal(t, 403, w.Code)
+	w = adminRequest(h, "DELETE", path, "releng-token", "")
+	assert.Equal(t, 204, w.Code)
+	w = adminRequest(h, "DELETE", path, "releng-token", "")
+	assert.Equal(t, 404, w.Code)
+}
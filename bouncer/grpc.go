@@ -0,0 +1,159 @@
+package bouncer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/mozilla-services/go-bouncer/bouncerpb"
+)
+
+// grpcServer implements the gRPC interface of bouncer on top of a
+// BouncerHandler, so that both interfaces resolve requests the same way.
+type grpcServer struct {
+	bouncerpb.UnimplementedBouncerServer
+
+	bouncer *BouncerHandler
+}
+
+// NewGRPCServer returns a gRPC server for the Bouncer service.
+func NewGRPCServer(bouncer *BouncerHandler) *grpc.Server {
+	server := grpc.NewServer()
+	bouncerpb.RegisterBouncerServer(server, &grpcServer{bouncer: bouncer})
+	return server
+}
+
+func (s *grpcServer) Resolve(_ context.Context, req *bouncerpb.ResolveRequest) (*bouncerpb.ResolveResponse, error) {
+	reqParams := &BouncerParams{
+		Product:   strings.TrimSpace(strings.ToLower(req.GetProduct())),
+		OS:        strings.TrimSpace(strings.ToLower(req.GetOs())),
+		Lang:      req.GetLang(),
+		Kind:      strings.TrimSpace(strings.ToLower(req.GetKind())),
+		UserAgent: req.GetUserAgent(),
+		Referer:   req.GetReferer(),
+	}
+	if reqParams.Product == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing product")
+	}
+	if !knownKind(reqParams.Kind) {
+		return nil, status.Error(codes.InvalidArgument, "invalid kind")
+	}
+	if reqParams.OS == "" {
+		reqParams.OS = defaultOS
+	}
+	if reqParams.Lang == "" {
+		reqParams.Lang = defaultLang
+	}
+
+	res, err := s.bouncer.Resolve(reqParams, nil, req.GetPinHttps())
+	if err != nil {
+		return nil, s.internalError(err)
+	}
+	if res == nil {
+		return nil, status.Error(codes.NotFound, "not found")
+	}
+	if err := validateRedirectURL(res.URL, s.bouncer.redirectHosts()); err != nil {
+		metrics.Add("redirect_rejected", 1)
+		return nil, s.internalError(fmt.Errorf("refusing to return %q: %v", res.URL, err))
+	}
+
+	return &bouncerpb.ResolveResponse{Resolution: resolutionToProto(res)}, nil
+}
+
+func (s *grpcServer) BatchResolve(_ context.Context, req *bouncerpb.BatchResolveRequest) (*bouncerpb.BatchResolveResponse, error) {
+	if len(req.GetItems()) == 0 || len(req.GetItems()) > maxBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "a batch must have between 1 and %d items", maxBatchSize)
+	}
+
+	items := make([]BatchItem, len(req.GetItems()))
+	for i, item := range req.GetItems() {
+		items[i] = BatchItem{
+			Product:   item.GetProduct(),
+			OS:        item.GetOs(),
+			Lang:      item.GetLang(),
+			UserAgent: item.GetUserAgent(),
+		}
+	}
+
+	results, err := s.bouncer.ResolveBatch(items, req.GetReferer(), req.GetUserAgent(), req.GetPinHttps())
+	if err != nil {
+		return nil, s.internalError(err)
+	}
+
+	resp := &bouncerpb.BatchResolveResponse{}
+	for _, result := range results {
+		if result.Error != "" {
+			resp.Items = append(resp.Items, &bouncerpb.BatchResult{
+				Result: &bouncerpb.BatchResult_Error{Error: result.Error},
+			})
+			continue
+		}
+		resp.Items = append(resp.Items, &bouncerpb.BatchResult{
+			Result: &bouncerpb.BatchResult_Resolution{Resolution: resolutionToProto(result.Resolution)},
+		})
+	}
+	return resp, nil
+}
+
+func (s *grpcServer) ListProducts(_ context.Context, req *bouncerpb.ListProductsRequest) (*bouncerpb.ListProductsResponse, error) {
+	limit := int(req.GetPageSize())
+	if limit == 0 {
+		limit = defaultPageLimit
+	}
+	if limit < 1 || limit > maxPageLimit {
+		return nil, status.Errorf(codes.InvalidArgument, "page_size must be between 1 and %d", maxPageLimit)
+	}
+
+	// Page tokens are offsets.
+	offset := 0
+	if token := req.GetPageToken(); token != "" {
+		var err error
+		if offset, err = strconv.Atoi(token); err != nil || offset < 0 {
+			return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+		}
+	}
+
+	// Fetch one more product to know whether there is a next page.
+	products, err := s.bouncer.db.Products(limit+1, offset)
+	if err != nil {
+		return nil, s.internalError(err)
+	}
+
+	resp := &bouncerpb.ListProductsResponse{}
+	if len(products) > limit {
+		products = products[:limit]
+		resp.NextPageToken = strconv.Itoa(offset + limit)
+	}
+	for _, product := range products {
+		resp.Products = append(resp.Products, &bouncerpb.Product{
+			Name:      product.Name,
+			SslOnly:   product.SSLOnly,
+			Languages: product.Languages,
+			Oses:      product.OSes,
+		})
+	}
+	return resp, nil
+}
+
+func (s *grpcServer) internalError(err error) error {
+	log.Printf("grpcServer err: %v", err)
+	return status.Error(codes.Internal, "internal error")
+}
+
+func resolutionToProto(res *Resolution) *bouncerpb.Resolution {
+	return &bouncerpb.Resolution{
+		Url:      res.URL,
+		Product:  res.Product,
+		Os:       res.OS,
+		Lang:     res.Lang,
+		Kind:     res.Kind,
+		SslOnly:  res.SSLOnly,
+		Override: res.Override,
+	}
+}
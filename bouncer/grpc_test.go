@@ -0,0 +1,119 @@
+package bouncer
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/mozilla-services/go-bouncer/bouncerpb"
+)
+
+func newTestGRPCClient(t *testing.T, bouncer *BouncerHandler) bouncerpb.BouncerClient {
+	listener := bufconn.Listen(1 << 20)
+	server := NewGRPCServer(bouncer)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return listener.Dial()
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	assert.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return bouncerpb.NewBouncerClient(conn)
+}
+
+func TestGRPCResolve(t *testing.T) {
+	client := newTestGRPCClient(t, bouncerHandler)
+	ctx := context.Background()
+
+	resp, err := client.Resolve(ctx, &bouncerpb.ResolveRequest{Product: "firefox-latest", Os: "osx", Lang: "en-US"})
+	assert.NoError(t, err)
+	assert.Equal(t, "http://download.cdn.mozilla.net/pub/firefox/releases/39.0/mac/en-US/Firefox%2039.0.dmg", resp.GetResolution().GetUrl())
+	assert.Equal(t, "Firefox", resp.GetResolution().GetProduct())
+
+	resp, err = client.Resolve(ctx, &bouncerpb.ResolveRequest{Product: "firefox-latest", Os: "osx", PinHttps: true, Kind: "signature"})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://download-installer.cdn.mozilla.net/pub/firefox/releases/39.0/mac/en-US/Firefox%2039.0.dmg.asc", resp.GetResolution().GetUrl())
+	assert.Equal(t, "signature", resp.GetResolution().GetKind())
+
+	// Overrides apply.
+	resp, err = client.Resolve(ctx, &bouncerpb.ResolveRequest{
+		Product:   "firefox-stub",
+		UserAgent: "Mozilla/5.0 (Windows NT 6.1; WOW64; Trident/7.0; rv:11.0) like Gecko",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, overrideESR115, resp.GetResolution().GetOverride())
+	assert.Equal(t, "win64", resp.GetResolution().GetOs())
+
+	_, err = client.Resolve(ctx, &bouncerpb.ResolveRequest{Product: "unknown-product"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.Resolve(ctx, &bouncerpb.ResolveRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGRPCResolveRejectsUnsafeURL(t *testing.T) {
+	client := newTestGRPCClient(t, bouncerHandler)
+
+	_, err := client.Resolve(context.Background(), &bouncerpb.ResolveRequest{Product: "Firefox-127.0b9", Os: "osx", Lang: "../../../evil"})
+	assert.Equal(t, codes.Internal, status.Code(err))
+}
+
+func TestGRPCBatchResolve(t *testing.T) {
+	client := newTestGRPCClient(t, bouncerHandler)
+
+	resp, err := client.BatchResolve(context.Background(), &bouncerpb.BatchResolveRequest{
+		Items: []*bouncerpb.BatchItem{
+			{Product: "firefox-latest", Os: "osx", Lang: "en-US"},
+			{Product: "unknown-product"},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, resp.GetItems(), 2)
+	assert.Equal(t, "http://download.cdn.mozilla.net/pub/firefox/releases/39.0/mac/en-US/Firefox%2039.0.dmg", resp.GetItems()[0].GetResolution().GetUrl())
+	assert.Equal(t, batchErrNotFound, resp.GetItems()[1].GetError())
+
+	_, err = client.BatchResolve(context.Background(), &bouncerpb.BatchResolveRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGRPCListProducts(t *testing.T) {
+	client := newTestGRPCClient(t, bouncerHandler)
+	ctx := context.Background()
+
+	resp, err := client.ListProducts(ctx, &bouncerpb.ListProductsRequest{PageSize: 2})
+	assert.NoError(t, err)
+	assert.Len(t, resp.GetProducts(), 2)
+	assert.Equal(t, "2", resp.GetNextPageToken())
+
+	next, err := client.ListProducts(ctx, &bouncerpb.ListProductsRequest{PageSize: 2, PageToken: resp.GetNextPageToken()})
+	assert.NoError(t, err)
+	assert.Len(t, next.GetProducts(), 2)
+	assert.NotEqual(t, resp.GetProducts()[0].GetName(), next.GetProducts()[0].GetName())
+
+	all, err := client.ListProducts(ctx, &bouncerpb.ListProductsRequest{PageSize: 1000})
+	assert.NoError(t, err)
+	assert.Empty(t, all.GetNextPageToken())
+	for _, product := range all.GetProducts() {
+		if product.GetName() == "Firefox-SSL" {
+			assert.True(t, product.GetSslOnly())
+			assert.Equal(t, []string{"en-GB", "en-US"}, product.GetLanguages())
+			assert.Equal(t, []string{"osx", "win", "win64"}, product.GetOses())
+		}
+	}
+
+	for _, req := range []*bouncerpb.ListProductsRequest{{PageSize: -1}, {PageSize: 1001}, {PageToken: "abc"}} {
+		_, err = client.ListProducts(ctx, req)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+}
@@ -0,0 +1,768 @@
+package bouncer
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	defaultLang          = "en-US"
+	defaultOS            = "win"
+	fxPre2024LastNightly = "firefox-nightly-pre2024"
+	fxPre2024LastBeta    = "127.0b9"
+	fxPre2024LastRelease = "127.0"
+	esr115Product        = "firefox-esr115-latest-ssl"
+)
+
+var (
+	// detects windows 7/8/8.1 clients
+	windowsRegexForESR115 = regexp.MustCompile(`Windows (?:NT 6\.(1|2|3))`)
+	// detects partner aliases
+	fxPartnerAlias = regexp.MustCompile(`^partner-firefox-release-([^-]*)-(.*)-latest$`)
+	// detects x64 clients
+	win64Regex = regexp.MustCompile(`Win64|WOW64`)
+)
+
+// IsUserAgentOnlyCompatibleWithESR115 detects Windows 7/8/8.1 clients, which
+// can't run Firefox releases after ESR115.
+func IsUserAgentOnlyCompatibleWithESR115(userAgent string) bool {
+	return windowsRegexForESR115.MatchString(userAgent)
+}
+
+// IsWin64UserAgent detects x64 Windows clients.
+func IsWin64UserAgent(userAgent string) bool {
+	return win64Regex.MatchString(userAgent)
+}
+
+// IsPre2024StubUserAgent is used to detect stub installers that pin the
+// "DigiCert SHA2 Assured ID Code Signing CA" intermediate.
+func IsPre2024StubUserAgent(userAgent string) bool {
+	return userAgent == "NSIS InetBgDL (Mozilla)"
+}
+
+// Pre2024Product returns the last product signed with the pre-2024
+// certificates, for the products whose latest builds can't be installed by old
+// stub installers.
+func Pre2024Product(product string) string {
+	productParts := strings.SplitN(product, "-", 2)
+	if len(productParts) < 2 {
+		return product
+	}
+
+	partnerMatch := fxPartnerAlias.FindStringSubmatch(product)
+	if partnerMatch != nil && partnerMatch[1] == "unitedinternet" {
+		return "firefox-" + fxPre2024LastRelease + "-unitedinternet-" + partnerMatch[2]
+	}
+
+	if productParts[0] != "firefox" {
+		return product
+	}
+
+	switch productParts[1] {
+	case "nightly-latest", "nightly-latest-l10n":
+		return fxPre2024LastNightly
+	case "nightly-latest-ssl", "nightly-latest-l10n-ssl":
+		return fxPre2024LastNightly + "-ssl"
+	case "beta-latest-ssl":
+		return "firefox-" + fxPre2024LastBeta + "-ssl"
+	case "beta-latest":
+		return "firefox-" + fxPre2024LastBeta
+	case "devedition-latest-ssl":
+		return "devedition-" + fxPre2024LastBeta + "-ssl"
+	case "devedition-latest":
+		return "devedition-" + fxPre2024LastBeta
+	case "latest-ssl":
+		return "firefox-" + fxPre2024LastRelease + "-ssl"
+	case "latest":
+		return "firefox-" + fxPre2024LastRelease
+	}
+
+	return product
+
+}
+
+// NewHealthHandler returns a HealthHandler checking db and, if not nil, the
+// stub attribution backends.
+func NewHealthHandler(db *DB, stubHealth *StubHealthChecker, cacheTime time.Duration) *HealthHandler {
+	return &HealthHandler{db: db, stubHealth: stubHealth, CacheTime: cacheTime}
+}
+
+// HealthHandler returns 200 if the app looks okay
+type HealthHandler struct {
+	db         *DB
+	stubHealth *StubHealthChecker
+
+	CacheTime time.Duration
+}
+
+func (h *HealthHandler) check() *HealthResult {
+	result := &HealthResult{
+		DB:      true,
+		Healthy: true,
+	}
+
+	err := h.db.Ping()
+	if err != nil {
+		result.DB = false
+		result.Healthy = false
+		log.Printf("HealthHandler err: %v", err)
+	}
+
+	if h.stubHealth != nil {
+		result.StubAttribution = h.stubHealth.Status()
+		for _, healthy := range result.StubAttribution {
+			if !healthy {
+				result.Degraded = true
+			}
+		}
+	}
+	return result
+}
+
+func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	if h.CacheTime > 0 {
+		w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", h.CacheTime/time.Second))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	result := h.check()
+	if !result.Healthy {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	w.Write(result.JSON())
+}
+
+// BouncerHandler is the primary handler for this application. It serves
+// downloads as resolved by its Resolver.
+type BouncerHandler struct {
+	Resolver
+}
+
+// Location is a download location for a product, OS and language.
+type Location struct {
+	ID string
+	// Product is the name of the product, after alias resolution.
+	Product string
+	SSLOnly bool
+	URL     string
+	// Path is the path of the location, before :lang substitution.
+	Path string
+}
+
+// URL returns the final redirect URL given a lang, os and product
+// if the string is == "", no mirror or location was found
+func (r *Resolver) URL(pinHTTPS bool, lang, os, product string) (string, error) {
+	location, err := r.ResolveLocation(pinHTTPS, lang, os, product)
+	if err != nil || location == nil {
+		return "", err
+	}
+	return location.URL, nil
+}
+
+// ResolveLocation returns the download location given a lang, os and product.
+// A nil Location means that no mirror or location was found.
+func (r *Resolver) ResolveLocation(pinHTTPS bool, lang, os, product string) (*Location, error) {
+	return r.resolveLocation(pinHTTPS, lang, os, product, time.Time{})
+}
+
+// resolveLocation is like ResolveLocation, with aliases resolved as of a given
+// time (the zero time being now).
+func (r *Resolver) resolveLocation(pinHTTPS bool, lang, os, product string, asOf time.Time) (*Location, error) {
+	var err error
+	if asOf.IsZero() {
+		product, err = r.db.AliasFor(product)
+	} else {
+		product, err = r.db.AliasAt(product, asOf)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	osID, err := r.db.OSID(os)
+	switch {
+	case err == sql.ErrNoRows:
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	productID, sslOnly, err := r.db.ProductForLanguage(product, lang)
+	switch {
+	case err == sql.ErrNoRows:
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	locationID, locationPath, err := r.db.Location(productID, osID)
+	switch {
+	case err == sql.ErrNoRows:
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	location := &Location{
+		ID:      locationID,
+		Product: product,
+		SSLOnly: sslOnly,
+		Path:    locationPath,
+	}
+	location.URL, err = r.locationURL(r.db.BaseURLsFor, pinHTTPS, lang, product, sslOnly, locationPath)
+	if err != nil {
+		return nil, err
+	}
+	return location, nil
+}
+
+// baseURLsLookup returns the base URLs of a product, or sql.ErrNoRows when the
+// product has no mapping of its own.
+type baseURLsLookup func(product string) (http, https string, err error)
+
+// locationURL turns the path of a location into a URL.
+func (r *Resolver) locationURL(lookup baseURLsLookup, pinHTTPS bool, lang, product string, sslOnly bool, locationPath string) (string, error) {
+	locationPath = strings.Replace(locationPath, ":lang", lang, -1)
+
+	// Absolute locations point outside of the CDN (e.g. a store listing) and
+	// bypass the base URLs entirely.
+	if isAbsoluteLocation(locationPath) {
+		return r.absoluteLocationURL(locationPath)
+	}
+
+	baseURLHttp, baseURLHttps, err := r.baseURLs(lookup, product)
+	if err != nil {
+		return "", err
+	}
+
+	mirrorBaseURL := "http://" + baseURLHttp
+	if pinHTTPS || sslOnly {
+		mirrorBaseURL = "https://" + baseURLHttps
+	}
+
+	return mirrorBaseURL + locationPath, nil
+}
+
+func isAbsoluteLocation(locationPath string) bool {
+	return strings.HasPrefix(locationPath, "https://") || strings.HasPrefix(locationPath, "http://")
+}
+
+// absoluteLocationURL validates an absolute location against the allowed hosts
+// and returns it.
+func (r *Resolver) absoluteLocationURL(location string) (string, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return "", fmt.Errorf("invalid absolute location %q: %v", location, err)
+	}
+
+	if u.Scheme != "https" {
+		return "", fmt.Errorf("absolute location %q must use https", location)
+	}
+
+	if !hostAllowed(u.Host, r.AbsoluteLocationHosts) {
+		return "", fmt.Errorf("absolute location %q points to a host that is not allowed", location)
+	}
+	return location, nil
+}
+
+// baseURLs returns the HTTP and HTTPS base URLs for a product. The pinned base
+// URLs are used when the product has no mapping of its own.
+func (r *Resolver) baseURLs(lookup baseURLsLookup, product string) (baseURLHttp, baseURLHttps string, err error) {
+	baseURLHttp, baseURLHttps, err = lookup(product)
+	if err != nil && err != sql.ErrNoRows {
+		return "", "", err
+	}
+
+	if baseURLHttp == "" {
+		baseURLHttp = r.PinnedBaseURLHttp
+	}
+	if baseURLHttps == "" {
+		baseURLHttps = r.PinnedBaseURLHttps
+	}
+	return baseURLHttp, baseURLHttps, nil
+}
+
+// redirectHosts returns all the hosts that bouncer may redirect to.
+func (r *Resolver) redirectHosts() []string {
+	hosts := []string{
+		baseURLHost(r.PinnedBaseURLHttp),
+		baseURLHost(r.PinnedBaseURLHttps),
+	}
+	for _, backend := range r.stubBackends() {
+		if u, err := url.Parse(backend.RootURL); err == nil {
+			hosts = append(hosts, u.Host)
+		}
+	}
+	mapped, err := r.db.mappedHosts()
+	if err != nil {
+		log.Printf("Could not load the hosts of the product base URLs: %v", err)
+	}
+	hosts = append(hosts, mapped...)
+	hosts = append(hosts, r.AbsoluteLocationHosts...)
+	return append(hosts, r.RedirectAllowedHosts...)
+}
+
+// checkRedirectURL is the last check before a URL is returned to a client. It
+// responds with a 500 and returns false when the URL isn't safe to redirect to.
+func (b *BouncerHandler) checkRedirectURL(w http.ResponseWriter, reqParams *BouncerParams, redirectURL string) bool {
+	err := validateRedirectURL(redirectURL, b.redirectHosts())
+	if err != nil {
+		metrics.Add("redirect_rejected", 1)
+		log.Printf("Refusing to redirect to %q: %v%s", redirectURL, err, b.logDetails(reqParams))
+		http.Error(w, "Internal Server Error.", http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+func (b *BouncerHandler) shouldPinHTTPS(req *http.Request) bool {
+	if b.PinHTTPSHeaderName == "" {
+		return false
+	}
+
+	return req.Header.Get(b.PinHTTPSHeaderName) == "https"
+}
+
+// hasPrivacySignal returns whether the request has a privacy signal that
+// bouncer has been configured to honour.
+func (r *Resolver) hasPrivacySignal(reqParams *BouncerParams) bool {
+	return (r.RespectGPC && reqParams.GPC) || (r.RespectDNT && reqParams.DNT)
+}
+
+// logDetails returns the referer and user agent of a request for log lines,
+// unless the request has a privacy signal.
+func (r *Resolver) logDetails(reqParams *BouncerParams) string {
+	if r.hasPrivacySignal(reqParams) {
+		return ""
+	}
+	return fmt.Sprintf(" (referer: %q, user agent: %q)", reqParams.Referer, reqParams.UserAgent)
+}
+
+// hasValidAttributionSig verifies the attribution signature, unless this is
+// disabled. In report mode, failures are logged but the signature is still
+// considered valid.
+func (r *Resolver) hasValidAttributionSig(reqParams *BouncerParams) bool {
+	if r.AttributionSigVerification != AttributionSigReport && r.AttributionSigVerification != AttributionSigEnforce {
+		return true
+	}
+
+	err := verifyAttributionSig(reqParams.AttributionCode, reqParams.AttributionSig, r.AttributionKeys)
+	if err == nil {
+		return true
+	}
+
+	metrics.Add("attribution_sig_invalid", 1)
+	log.Printf("Invalid attribution signature for product %s (mode: %s): %v%s", reqParams.Product, r.AttributionSigVerification, err, r.logDetails(reqParams))
+	return r.AttributionSigVerification != AttributionSigEnforce
+}
+
+// stubBackends returns the configured stub attribution backends, or a default
+// backend when only StubRootURL is set.
+func (r *Resolver) stubBackends() []StubBackend {
+	if len(r.StubBackends) > 0 {
+		return r.StubBackends
+	}
+	if r.StubRootURL != "" {
+		return []StubBackend{defaultStubBackend(r.StubRootURL)}
+	}
+	return nil
+}
+
+// attributionBackend returns the stub attribution backend that should handle
+// the request, or nil if the request should not be attributed.
+func (r *Resolver) attributionBackend(reqParams *BouncerParams) *StubBackend {
+	if reqParams.AttributionCode == "" {
+		return nil
+	}
+	if reqParams.AttributionSig == "" {
+		return nil
+	}
+
+	// Only installers are attributed.
+	if reqParams.Kind != "" && reqParams.Kind != KindInstaller {
+		return nil
+	}
+
+	// Users who opted out of tracking get the unattributed installer.
+	if r.hasPrivacySignal(reqParams) {
+		return nil
+	}
+
+	var backend *StubBackend
+	for _, candidate := range r.stubBackends() {
+		if candidate.Handles(reqParams.OS, reqParams.Product) {
+			backend = &candidate
+			break
+		}
+	}
+	if backend == nil {
+		return nil
+	}
+
+	if !r.hasValidAttributionSig(reqParams) {
+		return nil
+	}
+
+	code, err := ParseAttributionCode(reqParams.AttributionCode)
+	switch {
+	case errors.Is(err, errUndecodableAttributionCode):
+		// The stubattribution service has the final word on codes that we
+		// cannot decode, no policy applies to them.
+		metrics.Add("attribution_code_undecodable", 1)
+		return backend
+	case err != nil:
+		metrics.Add("attribution_code_invalid", 1)
+		log.Printf("Invalid attribution_code for product %s: %v%s", reqParams.Product, err, r.logDetails(reqParams))
+		return nil
+	}
+
+	for _, policy := range r.attributionPolicies() {
+		if !policy.Allows(code, reqParams.Referer, r.trustedSites()) {
+			return nil
+		}
+	}
+
+	return backend
+}
+
+// ShouldAttribute returns whether a request is sent to a stub attribution
+// service.
+func (r *Resolver) ShouldAttribute(reqParams *BouncerParams) bool {
+	return r.attributionBackend(reqParams) != nil
+}
+
+func (r *Resolver) attributionPolicies() []AttributionPolicy {
+	if r.AttributionPolicies == nil {
+		return defaultAttributionPolicies
+	}
+	return r.AttributionPolicies
+}
+
+func (r *Resolver) trustedSites() TrustedSites {
+	if r.TrustedSites == nil {
+		return defaultTrustedSites
+	}
+	return r.TrustedSites
+}
+
+// Overrides of the requested product.
+const (
+	overrideESR115  = "esr115"
+	overridePre2024 = "pre2024"
+)
+
+// Resolve applies the attribution and override rules to a request and looks
+// up where the client should be sent. query holds the original query params,
+// which can be forwarded to a stub attribution service. A nil Resolution
+// means that no location was found.
+func (r *Resolver) Resolve(reqParams *BouncerParams, query url.Values, pinHTTPS bool) (*Resolution, error) {
+	// If attribution_code is set, redirect to the stub service, unless it is
+	// down.
+	backend := r.attributionBackend(reqParams)
+	if backend != nil && !r.StubHealth.Healthy(backend.Name) {
+		metrics.Add("stub_fallback", 1)
+		backend = nil
+	}
+	if backend != nil {
+		return &Resolution{
+			URL:         backend.URL(reqParams, query),
+			Product:     reqParams.Product,
+			OS:          reqParams.OS,
+			Lang:        reqParams.Lang,
+			Attribution: true,
+		}, nil
+	}
+
+	product, os, override := r.overrideProduct(reqParams)
+
+	// Requests of a running experiment are not part of a rollout.
+	e, experiment, target, err := r.experimentProduct(product, override, reqParams)
+	if err != nil {
+		return nil, err
+	}
+	var rollout *RolloutBucket
+	if experiment == nil {
+		rollout, target, err = r.rolloutProduct(product, reqParams)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var location *Location
+	if target != "" {
+		location, err = r.resolveLocation(pinHTTPS, reqParams.Lang, os, target, reqParams.AsOf)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if experiment != nil {
+		experiment.Variant = location != nil
+	}
+	// Clients outside of the targets, or of a target or variant that isn't
+	// available in the requested OS or language, get the requested product.
+	if location == nil {
+		location, err = r.resolveLocation(pinHTTPS, reqParams.Lang, os, product, reqParams.AsOf)
+		if err != nil || location == nil {
+			return nil, err
+		}
+	}
+	if rollout != nil {
+		rolloutMetrics.Add(rollout.Alias+"/"+location.Product, 1)
+	}
+	if experiment != nil {
+		experimentMetrics.Add(e.Product+"/"+experiment.ID+"/"+location.Product, 1)
+	}
+
+	kind := reqParams.Kind
+	if kind == KindInstaller {
+		kind = ""
+	}
+	if kind != "" {
+		location, err = r.kindLocation(location, kind, reqParams.Lang, pinHTTPS)
+		if err != nil || location == nil {
+			return nil, err
+		}
+	}
+
+	var asOf *time.Time
+	if !reqParams.AsOf.IsZero() {
+		asOf = &reqParams.AsOf
+	}
+
+	return &Resolution{
+		URL:          location.URL,
+		Product:      location.Product,
+		OS:           os,
+		Lang:         reqParams.Lang,
+		Kind:         kind,
+		SSLOnly:      location.SSLOnly,
+		Override:     override,
+		AsOf:         asOf,
+		Rollout:      rollout,
+		Experiment:   experiment,
+		LocationID:   location.ID,
+		LocationPath: location.Path,
+	}, nil
+}
+
+// isESR115Candidate returns whether a request gets ESR115, unless it is
+// referred by a site exempted from this override.
+func isESR115Candidate(reqParams *BouncerParams) bool {
+	product, os := reqParams.Product, reqParams.OS
+
+	// We want to return ESR115 when... the product is for Firefox
+	return strings.HasPrefix(product, "firefox-") &&
+		// and the product is _not_ an MSI build
+		!strings.Contains(product, "-msi") &&
+		// and the product is _not_ a partial or complete update (MAR files)
+		!strings.Contains(product, "-partial") &&
+		!strings.Contains(product, "-complete") &&
+		// and the OS param specifies windows
+		strings.HasPrefix(os, "win") &&
+		// and the User-Agent says it's a Windows 7/8/8.1 client
+		IsUserAgentOnlyCompatibleWithESR115(reqParams.UserAgent)
+}
+
+// dependsOnReferer returns whether the response to a request may depend on
+// its referer, through the policies of trusted sites. Shared caches don't
+// know the trusted sites, so they must not store such responses.
+func (r *Resolver) dependsOnReferer(reqParams *BouncerParams) bool {
+	if isESR115Candidate(reqParams) {
+		return true
+	}
+	if reqParams.AttributionCode == "" {
+		return false
+	}
+	for _, policy := range r.attributionPolicies() {
+		if policy.RequireAllowedReferrer {
+			return true
+		}
+	}
+	return false
+}
+
+// overrideProduct returns the product and OS to serve instead of the requested
+// ones, along with the name of the override that applied, if any.
+func (r *Resolver) overrideProduct(reqParams *BouncerParams) (product, os, override string) {
+	product, os = reqParams.Product, reqParams.OS
+
+	shouldReturnESR115 := isESR115Candidate(reqParams) &&
+		// and the request doesn't come from a site exempted from this override
+		!r.trustedSites().Allows(reqParams.Referer, SitePolicyESRExemption)
+
+	// Send the latest compatible ESR product if we detect that this is the best option for the client.
+	if shouldReturnESR115 {
+		// Override the OS if we detect a x64 client that attempts to get a stub installer.
+		if strings.Contains(product, "-stub") && IsWin64UserAgent(reqParams.UserAgent) {
+			os = "win64"
+		}
+		product = esr115Product
+		override = overrideESR115
+	}
+
+	// If the user is an "old" stub installer, send a pre-2024-cert-rotation product.
+	if IsPre2024StubUserAgent(reqParams.UserAgent) {
+		if pre2024 := Pre2024Product(product); pre2024 != product {
+			product = pre2024
+			override = overridePre2024
+		}
+	}
+
+	return product, os, override
+}
+
+func (b *BouncerHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	b.serve(w, req, req.URL.Query())
+}
+
+// serve resolves a request given its params, which come from the query string
+// or from the path.
+func (b *BouncerHandler) serve(w http.ResponseWriter, req *http.Request, query url.Values) {
+	reqParams := BouncerParamsFromValues(query, req.Header)
+
+	if reqParams.Product == "" {
+		http.Redirect(w, req, "https://www.mozilla.org/", http.StatusFound)
+		return
+	}
+
+	if !knownKind(reqParams.Kind) {
+		http.Error(w, "Invalid kind.", http.StatusBadRequest)
+		return
+	}
+
+	if reqParams.OS == "" {
+		reqParams.OS = defaultOS
+	}
+
+	if reqParams.Lang == "" {
+		reqParams.Lang = defaultLang
+	}
+
+	// Past resolutions can be explained, but never served.
+	if reqParams.Print != "" {
+		asOf, err := parseAsOf(query)
+		if err != nil {
+			http.Error(w, "Invalid as_of, it must be an RFC 3339 timestamp.", http.StatusBadRequest)
+			return
+		}
+		reqParams.AsOf = asOf
+	}
+
+	// Metadata, like past resolutions, describes the unattributed build.
+	if reqParams.Print == printMeta || reqParams.Print == printMetalink || !reqParams.AsOf.IsZero() {
+		reqParams.AttributionCode = ""
+		reqParams.AttributionSig = ""
+	}
+
+	// Attributed responses depend on the privacy signals.
+	if reqParams.AttributionCode != "" {
+		if b.RespectGPC {
+			w.Header().Add("Vary", "Sec-GPC")
+		}
+		if b.RespectDNT {
+			w.Header().Add("Vary", "DNT")
+		}
+	}
+
+	reqParams.RolloutKey = b.rolloutKey(req)
+
+	pinHTTPS := b.shouldPinHTTPS(req)
+	res, err := b.Resolve(reqParams, query, pinHTTPS)
+	if err != nil {
+		http.Error(w, "Internal Server Error.", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	if res == nil {
+		http.NotFound(w, req)
+		return
+	}
+	if !b.checkRedirectURL(w, reqParams, res.URL) {
+		return
+	}
+
+	// Responses of a rollout, of an experiment with a traffic cap, or that
+	// depend on the referer, depend on the client, so they must not be
+	// stored by shared caches.
+	perClient := false
+	if res.Rollout != nil {
+		w.Header().Set("X-Rollout-Bucket", res.Rollout.String())
+		perClient = true
+	}
+	if res.Experiment != nil && res.Experiment.Bucket != nil {
+		w.Header().Set("X-Experiment-Bucket", res.Experiment.String())
+		perClient = true
+	}
+	if perClient && b.RolloutKeyHeader != "" {
+		w.Header().Add("Vary", b.RolloutKeyHeader)
+	}
+	if b.dependsOnReferer(reqParams) {
+		w.Header().Add("Vary", "Referer")
+		perClient = true
+	}
+
+	switch {
+	case b.CacheTime > 0 && perClient:
+		w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", b.CacheTime/time.Second))
+	case perClient:
+		w.Header().Set("Cache-Control", "private")
+	case b.CacheTime > 0 && !res.Attribution:
+		w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", b.CacheTime/time.Second))
+	}
+
+	switch {
+	// If ?print=json, describe the resolution instead of 302ing
+	case reqParams.Print == printJSON:
+		b.setCORSHeaders(w, req)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
+	// If ?print=meta, describe the build instead of 302ing
+	case reqParams.Print == printMeta:
+		metadata, err := b.metadata(res, pinHTTPS)
+		if err != nil {
+			http.Error(w, "Internal Server Error.", http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+		b.setCORSHeaders(w, req)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(metadata)
+	// If ?print=metalink, list all the URLs of the build instead of 302ing
+	case reqParams.Print == printMetalink:
+		metalink, err := b.metalink(res, pinHTTPS)
+		if err != nil {
+			http.Error(w, "Internal Server Error.", http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/metalink4+xml")
+		w.Write(metalink)
+	// If ?print=yes, print the resulting URL instead of 302ing
+	case reqParams.Print == printYes && !res.Attribution:
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(res.URL))
+	default:
+		if b.DigestHeaders && !res.Attribution {
+			b.setDigestHeaders(w, res)
+		}
+		http.Redirect(w, req, res.URL, http.StatusFound)
+	}
+}
+
+// setCORSHeaders allows trusted sites with the SitePolicyCORS policy to read
+// the response.
+func (b *BouncerHandler) setCORSHeaders(w http.ResponseWriter, req *http.Request) {
+	w.Header().Add("Vary", "Origin")
+
+	origin := req.Header.Get("Origin")
+	if origin != "" && b.trustedSites().Allows(origin, SitePolicyCORS) {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+}
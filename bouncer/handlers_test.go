@@ -1,12 +1,14 @@
-package main
+package bouncer
 
 import (
+	"expvar"
 	"fmt"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -21,13 +23,15 @@ func init() {
 		log.Fatal(err)
 	}
 
-	bouncerHandler = &BouncerHandler{
-		db:                 testDB,
+	bouncerHandler = NewBouncerHandler(testDB, Options{
 		StubRootURL:        "https://stub/",
 		PinHTTPSHeaderName: "X-Forwarded-Proto",
 		PinnedBaseURLHttp:  "download.cdn.mozilla.net/pub",
 		PinnedBaseURLHttps: "download-installer.cdn.mozilla.net/pub",
-	}
+		RespectGPC:         true,
+
+		AbsoluteLocationHosts: []string{"apps.microsoft.com"},
+	})
 }
 
 func TestShouldAttribute(t *testing.T) {
@@ -189,15 +193,135 @@ func TestShouldAttribute(t *testing.T) {
 			},
 			false,
 		},
+		{
+			&BouncerParams{
+				OS:              "win",
+				Product:         "Firefox",
+				AttributionCode: "att-code",
+				AttributionSig:  "att-sig",
+				GPC:             true,
+			},
+			false,
+		},
+		{
+			&BouncerParams{
+				OS:              "win",
+				Product:         "Firefox",
+				AttributionCode: "att-code",
+				AttributionSig:  "att-sig",
+				// DNT is not honoured by default.
+				DNT: true,
+			},
+			true,
+		},
 	}
 
 	for _, test := range tests {
-		t.Run(fmt.Sprintf("OS: %s, Product: %s, Code: %s, Sig: %s, Referer: %s", test.In.OS, test.In.Product, test.In.AttributionCode, test.In.AttributionSig, test.In.Referer), func(t *testing.T) {
-			assert.Equal(t, test.Out, bouncerHandler.shouldAttribute(test.In))
+		t.Run(fmt.Sprintf("OS: %s, Product: %s, Code: %s, Sig: %s, Referer: %s, GPC: %v, DNT: %v", test.In.OS, test.In.Product, test.In.AttributionCode, test.In.AttributionSig, test.In.Referer, test.In.GPC, test.In.DNT), func(t *testing.T) {
+			assert.Equal(t, test.Out, bouncerHandler.ShouldAttribute(test.In))
 		})
 	}
 }
 
+func TestShouldAttributeWithPrivacySignals(t *testing.T) {
+	params := &BouncerParams{
+		OS:              "win",
+		Product:         "Firefox",
+		AttributionCode: "att-code",
+		AttributionSig:  "att-sig",
+		GPC:             true,
+		DNT:             true,
+	}
+
+	h := *bouncerHandler
+	h.RespectGPC = false
+	h.RespectDNT = false
+	assert.True(t, h.ShouldAttribute(params))
+
+	h.RespectDNT = true
+	assert.False(t, h.ShouldAttribute(params))
+
+	params.DNT = false
+	assert.True(t, h.ShouldAttribute(params))
+}
+
+func TestLogDetails(t *testing.T) {
+	params := &BouncerParams{
+		Referer:   "https://www.mozilla.org/",
+		UserAgent: "Mozilla/5.0",
+	}
+	assert.Equal(t, ` (referer: "https://www.mozilla.org/", user agent: "Mozilla/5.0")`, bouncerHandler.logDetails(params))
+
+	params.GPC = true
+	assert.Equal(t, "", bouncerHandler.logDetails(params))
+}
+
+func TestBouncerHandlerPrivacySignals(t *testing.T) {
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "http://test/?product=Firefox&os=osx&lang=en-US&attribution_code=att-code&attribution_sig=anhmacsig", nil)
+	assert.NoError(t, err)
+	req.Header.Set("Sec-GPC", "1")
+
+	bouncerHandler.ServeHTTP(w, req)
+	assert.Equal(t, 302, w.Code)
+	assert.Equal(t, "http://download.cdn.mozilla.net/pub/firefox/releases/39.0/mac/en-US/Firefox%2039.0.dmg", w.Result().Header.Get("Location"))
+	assert.Equal(t, "Sec-GPC", w.Result().Header.Get("Vary"))
+}
+
+func TestShouldAttributeWithPolicies(t *testing.T) {
+	h := *bouncerHandler
+	h.AttributionPolicies = []AttributionPolicy{
+		{ContentPrefix: "blocked:", Disallow: true},
+	}
+
+	params := func(content string) *BouncerParams {
+		return &BouncerParams{
+			OS:              "win",
+			Product:         "firefox-stub",
+			AttributionCode: attributionCodeEncoding.EncodeToString([]byte("source=google.com&content=" + content)),
+			AttributionSig:  "att-sig",
+		}
+	}
+
+	assert.False(t, h.ShouldAttribute(params("blocked:foo")))
+	assert.True(t, h.ShouldAttribute(params("other")))
+	// The default RTAMO policy has been replaced.
+	assert.True(t, h.ShouldAttribute(params("rta:foo")))
+	assert.False(t, bouncerHandler.ShouldAttribute(params("rta:foo")))
+}
+
+func TestShouldAttributeWithSigVerification(t *testing.T) {
+	key := []byte("test-key")
+	code := "c291cmNlPWdvb2dsZS5jb20mbWVkaXVtPW9yZ2FuaWM."
+
+	tests := []struct {
+		Mode string
+		Sig  string
+		Out  bool
+	}{
+		{AttributionSigOff, "att-sig", true},
+		{AttributionSigReport, "att-sig", true},
+		{AttributionSigReport, signAttributionCode(code, key), true},
+		{AttributionSigEnforce, "att-sig", false},
+		{AttributionSigEnforce, signAttributionCode(code, []byte("other-key")), false},
+		{AttributionSigEnforce, signAttributionCode(code, key), true},
+	}
+
+	for _, test := range tests {
+		h := *bouncerHandler
+		h.AttributionSigVerification = test.Mode
+		h.AttributionKeys = [][]byte{key}
+
+		params := &BouncerParams{
+			OS:              "win",
+			Product:         "firefox-stub",
+			AttributionCode: code,
+			AttributionSig:  test.Sig,
+		}
+		assert.Equal(t, test.Out, h.ShouldAttribute(params), "mode: %s, sig: %s", test.Mode, test.Sig)
+	}
+}
+
 func TestBouncerHandlerAttributionCode(t *testing.T) {
 	tests := []struct {
 		In  string
@@ -228,6 +352,59 @@ func TestBouncerHandlerAttributionCode(t *testing.T) {
 	}
 }
 
+func TestBouncerHandlerStubBackends(t *testing.T) {
+	h := *bouncerHandler
+	h.StubBackends = []StubBackend{
+		{
+			Name:          "dmg",
+			RootURL:       "https://dmg-stub/",
+			OSes:          []string{"osx"},
+			ForwardParams: []string{"funnelcake"},
+		},
+		defaultStubBackend("https://stub/"),
+	}
+
+	tests := []struct {
+		In  string
+		Out string
+	}{
+		{
+			`http://test/?product=Firefox&os=osx&lang=en-US&attribution_code=att-code&attribution_sig=anhmacsig&funnelcake=137`,
+			`https://dmg-stub/?attribution_code=att-code&attribution_sig=anhmacsig&funnelcake=137&lang=en-US&os=osx&product=firefox`,
+		},
+		{
+			`http://test/?product=Firefox&os=win&lang=en-US&attribution_code=att-code&attribution_sig=anhmacsig&funnelcake=137`,
+			`https://stub/?attribution_code=att-code&attribution_sig=anhmacsig&lang=en-US&os=win&product=firefox`,
+		},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+
+		req, err := http.NewRequest("GET", test.In, nil)
+		assert.NoError(t, err)
+
+		h.ServeHTTP(w, req)
+		assert.Equal(t, 302, w.Code)
+		assert.Equal(t, test.Out, w.Result().Header.Get("Location"))
+	}
+}
+
+func TestBouncerHandlerStubFallback(t *testing.T) {
+	h := *bouncerHandler
+	h.StubHealth = NewStubHealthChecker([]StubBackend{defaultStubBackend("http://127.0.0.1:1/")}, time.Minute, time.Second)
+	for i := 0; i < stubUnhealthyAfter; i++ {
+		h.StubHealth.checkAll()
+	}
+
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "http://test/?product=Firefox&os=osx&lang=en-US&attribution_code=att-code&attribution_sig=anhmacsig", nil)
+	assert.NoError(t, err)
+
+	h.ServeHTTP(w, req)
+	assert.Equal(t, 302, w.Code)
+	assert.Equal(t, "http://download.cdn.mozilla.net/pub/firefox/releases/39.0/mac/en-US/Firefox%2039.0.dmg", w.Result().Header.Get("Location"))
+}
+
 func TestBouncerHandlerParams(t *testing.T) {
 	w := httptest.NewRecorder()
 
@@ -270,6 +447,131 @@ func TestBouncerHandlerPrintQuery(t *testing.T) {
 	assert.Equal(t, "http://download.cdn.mozilla.net/pub/firefox/releases/39.0/mac/en-US/Firefox%2039.0.dmg", w.Body.String())
 }
 
+func TestBouncerHandlerPrintJSON(t *testing.T) {
+	tests := []struct {
+		URL       string
+		UserAgent string
+		Expected  string
+	}{
+		{
+			"http://test/?product=firefox-latest&os=osx&lang=en-US&print=json",
+			"",
+			`{"url":"http://download.cdn.mozilla.net/pub/firefox/releases/39.0/mac/en-US/Firefox%2039.0.dmg","product":"Firefox","os":"osx","lang":"en-US","ssl_only":false,"attribution":false}`,
+		},
+		{
+			"http://test/?product=firefox-stub&os=win&lang=en-US&print=json",
+			"Mozilla/5.0 (Windows NT 6.1; WOW64; Trident/7.0; rv:11.0) like Gecko",
+			`{"url":"https://download-installer.cdn.mozilla.net/pub/firefox/releases/115.16.1esr/win64/en-US/Firefox%20Setup%20115.16.1esr.exe","product":"Firefox-115.16.1esr-SSL","os":"win64","lang":"en-US","ssl_only":true,"override":"esr115","attribution":false}`,
+		},
+		{
+			"http://test/?product=Firefox-nightly-latest-ssl&os=win&lang=en-US&print=json",
+			"NSIS InetBgDL (Mozilla)",
+			`{"url":"https://download-installer.cdn.mozilla.net/pub/firefox/nightly/2024/05/2024-05-06-09-48-55-mozilla-central-l10n/firefox-127.0a1.en-US.win32.installer.exe","product":"firefox-nightly-pre2024-ssl","os":"win","lang":"en-US","ssl_only":true,"override":"pre2024","attribution":false}`,
+		},
+		{
+			"http://test/?product=Firefox&os=osx&lang=en-US&attribution_code=att-code&attribution_sig=anhmacsig&print=json",
+			"",
+			`{"url":"https://stub/?attribution_code=att-code\u0026attribution_sig=anhmacsig\u0026lang=en-US\u0026os=osx\u0026product=firefox","product":"firefox","os":"osx","lang":"en-US","ssl_only":false,"attribution":true}`,
+		},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", test.URL, nil)
+		assert.NoError(t, err)
+		req.Header.Set("User-Agent", test.UserAgent)
+
+		bouncerHandler.ServeHTTP(w, req)
+		assert.Equal(t, 200, w.Code, "url: %v", test.URL)
+		assert.Equal(t, "application/json", w.Result().Header.Get("Content-Type"), "url: %v", test.URL)
+		assert.JSONEq(t, test.Expected, w.Body.String(), "url: %v", test.URL)
+	}
+}
+
+func TestBouncerHandlerPrintAsOf(t *testing.T) {
+	for _, tc := range []struct {
+		url      string
+		code     int
+		expected string
+	}{
+		{
+			"http://test/?product=firefox-beta-latest&os=osx&lang=en-US&print=json&as_of=2024-05-15T00:00:00Z",
+			200,
+			`{"url":"http://download.cdn.mozilla.net/pub/firefox/releases/127.0b9/mac/en-US/Firefox%20Setup%20127.0b9.exe","product":"Firefox-127.0b9","os":"osx","lang":"en-US","ssl_only":false,"attribution":false,"as_of":"2024-05-15T00:00:00Z"}`,
+		},
+		{
+			"http://test/?product=firefox-beta-latest&os=osx&lang=en-US&print=json&as_of=2024-07-01T00:00:00%2B02:00",
+			200,
+			`{"url":"http://download.cdn.mozilla.net/pub/firefox/releases/39.0/mac/en-US/Firefox%2039.0.dmg","product":"Firefox","os":"osx","lang":"en-US","ssl_only":false,"attribution":false,"as_of":"2024-07-01T00:00:00+02:00"}`,
+		},
+		// Aliases without history resolve to their current product.
+		{
+			"http://test/?product=firefox-latest&os=osx&lang=en-US&print=yes&as_of=2024-05-15T00:00:00Z",
+			200,
+			"http://download.cdn.mozilla.net/pub/firefox/releases/39.0/mac/en-US/Firefox%2039.0.dmg",
+		},
+		// The alias didn't exist yet.
+		{
+			"http://test/?product=firefox-beta-latest&os=osx&lang=en-US&print=yes&as_of=2024-04-01T00:00:00Z",
+			404,
+			"404 page not found\n",
+		},
+		// Past resolutions are never attributed.
+		{
+			"http://test/?product=firefox-beta-latest&os=osx&lang=en-US&attribution_code=att-code&attribution_sig=anhmacsig&print=yes&as_of=2024-05-15T00:00:00Z",
+			200,
+			"http://download.cdn.mozilla.net/pub/firefox/releases/127.0b9/mac/en-US/Firefox%20Setup%20127.0b9.exe",
+		},
+		{
+			"http://test/?product=firefox-beta-latest&os=osx&lang=en-US&print=yes&as_of=yesterday",
+			400,
+			"Invalid as_of, it must be an RFC 3339 timestamp.\n",
+		},
+	} {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", tc.url, nil)
+		assert.NoError(t, err)
+
+		bouncerHandler.ServeHTTP(w, req)
+		assert.Equal(t, tc.code, w.Code, "url: %v", tc.url)
+		if strings.HasPrefix(tc.expected, "{") {
+			assert.JSONEq(t, tc.expected, w.Body.String(), "url: %v", tc.url)
+		} else {
+			assert.Equal(t, tc.expected, w.Body.String(), "url: %v", tc.url)
+		}
+	}
+
+	// Redirects are always current.
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "http://test/?product=firefox-beta-latest&os=osx&lang=en-US&as_of=2024-05-15T00:00:00Z", nil)
+	assert.NoError(t, err)
+	bouncerHandler.ServeHTTP(w, req)
+	assert.Equal(t, 302, w.Code)
+	assert.Equal(t, "http://download.cdn.mozilla.net/pub/firefox/releases/39.0/mac/en-US/Firefox%2039.0.dmg", w.Result().Header.Get("Location"))
+}
+
+func TestBouncerHandlerPrintJSONCORS(t *testing.T) {
+	for _, tc := range []struct {
+		origin      string
+		allowOrigin string
+	}{
+		{"https://www.mozilla.org", "https://www.mozilla.org"},
+		{"https://www.firefox.com", "https://www.firefox.com"},
+		{"https://example.com", ""},
+		{"", ""},
+	} {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", "http://test/?product=firefox-latest&os=osx&lang=en-US&print=json", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Origin", tc.origin)
+
+		bouncerHandler.ServeHTTP(w, req)
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, tc.allowOrigin, w.Result().Header.Get("Access-Control-Allow-Origin"), "origin: %v", tc.origin)
+		assert.Equal(t, "Origin", w.Result().Header.Get("Vary"))
+	}
+}
+
 func TestBouncerHandlerValid(t *testing.T) {
 	defaultUA := "Mozilla/5.0 (Windows NT 7.0; rv:10.0) Gecko/20100101 Firefox/43.0"
 	testRequests := []struct {
@@ -324,6 +626,82 @@ func TestBouncerHandlerValid(t *testing.T) {
 	}
 }
 
+func TestBouncerHandlerProductBaseURL(t *testing.T) {
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "http://test/?product=thunderbird-latest-ssl&os=win64&lang=en-US", nil)
+	assert.NoError(t, err)
+
+	bouncerHandler.ServeHTTP(w, req)
+	assert.Equal(t, 302, w.Code)
+	assert.Equal(t, "https://download-installer.cdn.thunderbird.net/pub/thunderbird/releases/131.0.1/win64/en-US/Thunderbird%20Setup%20131.0.1.exe", w.Result().Header.Get("Location"))
+}
+
+func TestBouncerHandlerAbsoluteLocation(t *testing.T) {
+	testRequests := []struct {
+		URL              string
+		ExpectedCode     int
+		ExpectedLocation string
+	}{
+		{"http://test/?product=firefox-store-latest-ssl&os=win64&lang=fr", 302, "https://apps.microsoft.com/detail/9nzvdkpmr9rd?hl=fr"},
+		{"http://test/?product=firefox-store-latest-ssl&os=osx&lang=fr", 302, "https://download-installer.cdn.mozilla.net/pub/firefox/releases/39.0/mac/fr/Firefox%2039.0.dmg"},
+		// This location points to a host that isn't allowed.
+		{"http://test/?product=firefox-store-latest-ssl&os=win&lang=fr", 500, ""},
+	}
+
+	for _, testRequest := range testRequests {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", testRequest.URL, nil)
+		assert.NoError(t, err)
+
+		bouncerHandler.ServeHTTP(w, req)
+		assert.Equal(t, testRequest.ExpectedCode, w.Code, "url: %v", testRequest.URL)
+		assert.Equal(t, testRequest.ExpectedLocation, w.Result().Header.Get("Location"), "url: %v", testRequest.URL)
+	}
+}
+
+func TestBouncerHandlerRejectsUnsafeRedirect(t *testing.T) {
+	rejected := func() int64 {
+		if v, ok := metrics.Get("redirect_rejected").(*expvar.Int); ok {
+			return v.Value()
+		}
+		return 0
+	}
+	before := rejected()
+
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "http://test/?product=Firefox-127.0b9&os=osx&lang=../../../evil", nil)
+	assert.NoError(t, err)
+
+	bouncerHandler.ServeHTTP(w, req)
+	assert.Equal(t, 500, w.Code)
+	assert.Equal(t, "", w.Result().Header.Get("Location"))
+	assert.Equal(t, before+1, rejected())
+
+	// The same check applies to ?print=yes.
+	w = httptest.NewRecorder()
+	req, err = http.NewRequest("GET", "http://test/?product=Firefox-127.0b9&os=osx&lang=../../../evil&print=yes", nil)
+	assert.NoError(t, err)
+
+	bouncerHandler.ServeHTTP(w, req)
+	assert.Equal(t, 500, w.Code)
+	assert.Equal(t, before+2, rejected())
+}
+
+func TestRedirectHostsBaseURLMappings(t *testing.T) {
+	// The hosts of mirror_product_baseurls are allowed without being listed.
+	hosts := bouncerHandler.redirectHosts()
+	assert.Contains(t, hosts, "download.cdn.thunderbird.net")
+	assert.Contains(t, hosts, "download-installer.cdn.thunderbird.net")
+
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "http://test/?product=thunderbird-131.0.1-ssl&os=win&lang=en-US", nil)
+	assert.NoError(t, err)
+
+	bouncerHandler.ServeHTTP(w, req)
+	assert.Equal(t, 302, w.Code)
+	assert.Equal(t, "https://download-installer.cdn.thunderbird.net/pub/thunderbird/releases/131.0.1/win32/en-US/Thunderbird%20Setup%20131.0.1.exe", w.Result().Header.Get("Location"))
+}
+
 func TestBouncerHandlerPre2024(t *testing.T) {
 	testRequests := []struct {
 		URL string
@@ -360,7 +738,7 @@ func TestIsUserAgentOnlyCompatibleWithESR115(t *testing.T) {
 		{"Mozilla/5.0 (Windows NT 611; WOW64; Trident/7.0; rv:11.0) like Gecko", false},                                                                // Bogus
 	}
 	for _, ua := range uas {
-		assert.Equal(t, ua.IsWin7, isUserAgentOnlyCompatibleWithESR115(ua.UA), "ua: %v", ua.UA)
+		assert.Equal(t, ua.IsWin7, IsUserAgentOnlyCompatibleWithESR115(ua.UA), "ua: %v", ua.UA)
 	}
 }
 
@@ -381,7 +759,7 @@ func TestIsWin64UserAgent(t *testing.T) {
 		{"Mozilla/5.0 (Windows NT 6.3; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/100.0.4896.127 Safari/537.36 Edg/100.0.1185.44", true}, // Edge 100 64bits (Windows 7 SP1)
 	}
 	for _, ua := range uas {
-		assert.Equal(t, ua.IsWin64, isWin64UserAgent(ua.UA), "ua: %v", ua.UA)
+		assert.Equal(t, ua.IsWin64, IsWin64UserAgent(ua.UA), "ua: %v", ua.UA)
 	}
 }
 
@@ -523,6 +901,44 @@ func TestBouncerHandlerForWindowsOnlyCompatibleWithESR115WithFxcReferrer(t *test
 	assert.Equal(t, expectedLocation, w.Result().Header.Get("Location"))
 }
 
+func TestBouncerHandlerForWindowsOnlyCompatibleWithESR115WithTrustedSites(t *testing.T) {
+	h := *bouncerHandler
+	h.TrustedSites = TrustedSites{
+		{Origin: "https://www.allizom.org", Policies: []string{SitePolicyESRExemption}},
+	}
+
+	for _, tc := range []struct {
+		referrer         string
+		expectedLocation string
+	}{
+		{"https://www.allizom.org/", "http://download.cdn.mozilla.net/pub/firefox/releases/39.0/win32/en-US/Firefox%20Setup%2039.0.exe"},
+		// www.mozilla.org is no longer trusted with this registry.
+		{"https://www.mozilla.org/", "https://download-installer.cdn.mozilla.net/pub/firefox/releases/115.16.1esr/win32/en-US/Firefox%20Setup%20115.16.1esr.exe"},
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "http://test/?product=firefox-latest&os=win&lang=en-US", nil)
+		req.Header.Set("Referer", tc.referrer)
+		req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 6.3; WOW64; rv:124.0) Gecko/20100101 Firefox/124.0")
+
+		h.ServeHTTP(w, req)
+
+		assert.Equal(t, 302, w.Code, "referrer: %v", tc.referrer)
+		assert.Equal(t, tc.expectedLocation, w.Result().Header.Get("Location"), "referrer: %v", tc.referrer)
+		// Shared caches don't know the trusted sites.
+		assert.Equal(t, "private", w.Result().Header.Get("Cache-Control"), "referrer: %v", tc.referrer)
+		assert.Equal(t, "Referer", w.Result().Header.Get("Vary"), "referrer: %v", tc.referrer)
+	}
+
+	// Other clients don't depend on the referer.
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://test/?product=firefox-latest&os=osx&lang=en-US", nil)
+	req.Header.Set("Referer", "https://www.allizom.org/")
+	h.CacheTime = 10 * time.Minute
+	h.ServeHTTP(w, req)
+	assert.Equal(t, "max-age=600", w.Result().Header.Get("Cache-Control"))
+	assert.Empty(t, w.Result().Header.Get("Vary"))
+}
+
 func TestHealthHandler(t *testing.T) {
 	testDB, err := NewDB(testDSN)
 	if err != nil {
@@ -542,3 +958,29 @@ func TestHealthHandler(t *testing.T) {
 	assert.Equal(t, 200, w.Code)
 	assert.Equal(t, `{"db":true,"healthy":true}`, w.Body.String())
 }
+
+func TestHealthHandlerWithUnhealthyStub(t *testing.T) {
+	testDB, err := NewDB(testDSN)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	stubHealth := NewStubHealthChecker([]StubBackend{defaultStubBackend("http://127.0.0.1:1/")}, time.Minute, time.Second)
+	for i := 0; i < stubUnhealthyAfter; i++ {
+		stubHealth.checkAll()
+	}
+
+	h := &HealthHandler{
+		db:         testDB,
+		stubHealth: stubHealth,
+	}
+	w := httptest.NewRecorder()
+
+	req, err := http.NewRequest("GET", "/", nil)
+	assert.NoError(t, err)
+
+	h.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, `{"db":true,"healthy":true,"degraded":true,"stub_attribution":{"default":false}}`, w.Body.String())
+}
@@ -0,0 +1,159 @@
+package bouncer
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/mozilla-services/go-bouncer/bouncerapi"
+)
+
+// Kinds of resources that can be requested with the kind param.
+const (
+	// KindInstaller is the build itself, i.e. the path of the location.
+	KindInstaller = bouncerapi.KindInstaller
+	// KindSignature is the detached GPG signature of the build.
+	KindSignature = bouncerapi.KindSignature
+	// KindChecksums is the SHA256SUMS file of the release.
+	KindChecksums = bouncerapi.KindChecksums
+	// KindReleaseNotes is the release notes page of the release.
+	KindReleaseNotes = bouncerapi.KindReleaseNotes
+)
+
+var (
+	knownKinds = []string{KindInstaller, KindSignature, KindChecksums, KindReleaseNotes}
+
+	// defaultKindRules are used when no kind rules are configured. There is
+	// no default rule for release notes, which live on another site.
+	defaultKindRules = []KindRule{
+		mustKindRule(KindSignature, "", `^(/.+)$`, `${1}.asc`),
+		mustKindRule(KindChecksums, "", `^(/[^/]+/releases/[^/]+)/`, `${1}/SHA256SUMS`),
+	}
+)
+
+// knownKind reports whether kind can be requested. An empty kind is the
+// installer.
+func knownKind(kind string) bool {
+	return kind == "" || slices.Contains(knownKinds, kind)
+}
+
+// KindRule derives the path of a kind of resource from the path of a location.
+// Rules are built with NewKindRule or loaded with LoadConfig, which compile
+// their pattern.
+type KindRule struct {
+	Kind string `json:"kind"`
+	// ProductPrefix restricts the rule to the products whose name, after
+	// alias resolution, starts with it.
+	ProductPrefix string `json:"product_prefix,omitempty"`
+	// Pattern is matched against the path of the location, before :lang
+	// substitution.
+	Pattern string `json:"pattern"`
+	// Template is the path of the resource, where $1, ${name}, etc. are
+	// replaced by the submatches of Pattern. It can be an absolute URL, which
+	// is subject to the same rules as absolute locations.
+	Template string `json:"template"`
+
+	re *regexp.Regexp
+}
+
+// NewKindRule returns a KindRule, or an error when the kind is unsupported or
+// the pattern is invalid.
+func NewKindRule(kind, productPrefix, pattern, template string) (KindRule, error) {
+	r := KindRule{Kind: kind, ProductPrefix: productPrefix, Pattern: pattern, Template: template}
+	return r, r.validate()
+}
+
+func mustKindRule(kind, productPrefix, pattern, template string) KindRule {
+	r, err := NewKindRule(kind, productPrefix, pattern, template)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func (r *KindRule) validate() error {
+	if !slices.Contains(knownKinds, r.Kind) || r.Kind == KindInstaller {
+		return fmt.Errorf("kind rule: unsupported kind %q", r.Kind)
+	}
+	if r.Template == "" {
+		return fmt.Errorf("kind rule for %s: missing template", r.Kind)
+	}
+	re, err := regexp.Compile(r.Pattern)
+	if err != nil {
+		return fmt.Errorf("kind rule for %s: %v", r.Kind, err)
+	}
+	r.re = re
+	return nil
+}
+
+// apply returns the path of the resource, if the rule applies to the product
+// and location path. Rules that weren't compiled never apply.
+func (r *KindRule) apply(product, locationPath string) (string, bool) {
+	if r.re == nil || !strings.HasPrefix(strings.ToLower(product), strings.ToLower(r.ProductPrefix)) {
+		return "", false
+	}
+
+	match := r.re.FindStringSubmatchIndex(locationPath)
+	if match == nil {
+		return "", false
+	}
+	return string(r.re.ExpandString(nil, r.Template, locationPath, match)), true
+}
+
+func (r *Resolver) kindRules() []KindRule {
+	if r.KindRules == nil {
+		return defaultKindRules
+	}
+	return r.KindRules
+}
+
+// kindLocation returns the location of another kind of resource than the
+// installer of a location. The signature path stored in the metadata of the
+// location takes precedence over the kind rules. A nil Location means that
+// the resource is unknown.
+func (r *Resolver) kindLocation(location *Location, kind, lang string, pinHTTPS bool) (*Location, error) {
+	kindPath := ""
+
+	if kind == KindSignature && location.ID != "" {
+		metadata, err := r.db.MetadataFor(location.ID, lang)
+		switch {
+		case err == nil:
+			kindPath = metadata.SignaturePath
+		case err != sql.ErrNoRows:
+			return nil, err
+		}
+	}
+
+	if kindPath == "" {
+		rules := r.kindRules()
+		for i := range rules {
+			if rules[i].Kind != kind {
+				continue
+			}
+			if path, ok := rules[i].apply(location.Product, location.Path); ok {
+				kindPath = path
+				break
+			}
+		}
+	}
+
+	if kindPath == "" {
+		return nil, nil
+	}
+
+	kindURL, err := r.locationURL(r.db.BaseURLsFor, pinHTTPS, lang, location.Product, location.SSLOnly, kindPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// The resource has no ID: the metadata of the location doesn't apply to
+	// it.
+	return &Location{
+		Product: location.Product,
+		SSLOnly: location.SSLOnly,
+		URL:     kindURL,
+		Path:    kindPath,
+	}, nil
+}
@@ -0,0 +1,126 @@
+package bouncer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKindRuleApply(t *testing.T) {
+	for _, tc := range []struct {
+		rule     KindRule
+		product  string
+		path     string
+		expected string
+		ok       bool
+	}{
+		{defaultKindRules[0], "Firefox", "/firefox/releases/39.0/mac/:lang/Firefox%2039.0.dmg", "/firefox/releases/39.0/mac/:lang/Firefox%2039.0.dmg.asc", true},
+		{defaultKindRules[0], "Firefox", "https://apps.microsoft.com/detail/9nzvdkpmr9rd", "", false},
+		{defaultKindRules[1], "Firefox", "/firefox/releases/39.0/mac/:lang/Firefox%2039.0.dmg", "/firefox/releases/39.0/SHA256SUMS", true},
+		{defaultKindRules[1], "Firefox", "/firefox/nightly/latest-mozilla-central/firefox.exe", "", false},
+		{
+			mustKindRule(KindReleaseNotes, "firefox", `^/firefox/releases/([0-9.]+)/`, `https://www.mozilla.org/firefox/${1}/releasenotes/`),
+			"Firefox-SSL", "/firefox/releases/39.0/win32/:lang/Firefox%20Setup%2039.0.exe", "https://www.mozilla.org/firefox/39.0/releasenotes/", true,
+		},
+		{
+			mustKindRule(KindReleaseNotes, "Thunderbird", `^/firefox/releases/([0-9.]+)/`, `https://www.mozilla.org/firefox/${1}/releasenotes/`),
+			"Firefox-SSL", "/firefox/releases/39.0/win32/:lang/Firefox%20Setup%2039.0.exe", "", false,
+		},
+	} {
+		path, ok := tc.rule.apply(tc.product, tc.path)
+		assert.Equal(t, tc.ok, ok, tc.path)
+		assert.Equal(t, tc.expected, path, tc.path)
+	}
+}
+
+func TestBouncerHandlerKind(t *testing.T) {
+	h := *bouncerHandler
+	h.AbsoluteLocationHosts = []string{"apps.microsoft.com", "www.mozilla.org"}
+	releaseNotes, err := NewKindRule(KindReleaseNotes, "Firefox", `^/firefox/releases/([0-9.]+)/`, `https://www.mozilla.org/firefox/${1}/releasenotes/`)
+	assert.NoError(t, err)
+	h.KindRules = append([]KindRule{releaseNotes}, defaultKindRules...)
+
+	tests := []struct {
+		URL      string
+		Expected string
+	}{
+		{
+			"http://test/?product=firefox-latest&os=osx&lang=en-US&kind=installer",
+			"http://download.cdn.mozilla.net/pub/firefox/releases/39.0/mac/en-US/Firefox%2039.0.dmg",
+		},
+		{
+			"http://test/?product=firefox-latest&os=osx&lang=en-US&kind=signature",
+			"http://download.cdn.mozilla.net/pub/firefox/releases/39.0/mac/en-US/Firefox%2039.0.dmg.asc",
+		},
+		{
+			// From the metadata of the location.
+			"http://test/?product=firefox-latest-ssl&os=win&lang=en-US&kind=signature",
+			"https://download-installer.cdn.mozilla.net/pub/firefox/releases/39.0/win32/en-US/Firefox%20Setup%2039.0.exe.asc",
+		},
+		{
+			"http://test/?product=firefox-latest-ssl&os=win&lang=en-US&kind=Checksums",
+			"https://download-installer.cdn.mozilla.net/pub/firefox/releases/39.0/SHA256SUMS",
+		},
+		{
+			"http://test/?product=firefox-latest-ssl&os=win&lang=en-US&kind=release-notes",
+			"https://www.mozilla.org/firefox/39.0/releasenotes/",
+		},
+		{
+			// Attribution only applies to installers.
+			"http://test/?product=Firefox&os=osx&lang=en-US&attribution_code=att-code&attribution_sig=anhmacsig&kind=signature",
+			"http://download.cdn.mozilla.net/pub/firefox/releases/39.0/mac/en-US/Firefox%2039.0.dmg.asc",
+		},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", test.URL, nil)
+		assert.NoError(t, err)
+
+		h.ServeHTTP(w, req)
+		assert.Equal(t, 302, w.Code, "url: %v", test.URL)
+		assert.Equal(t, test.Expected, w.Result().Header.Get("Location"), "url: %v", test.URL)
+	}
+
+	for _, url := range []string{
+		// No rule for this kind.
+		"http://test/?product=firefox-latest&os=osx&lang=en-US&kind=release-notes&print=yes",
+	} {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", url, nil)
+		assert.NoError(t, err)
+
+		bouncerHandler.ServeHTTP(w, req)
+		assert.Equal(t, 404, w.Code, "url: %v", url)
+	}
+
+	// Unknown kinds are rejected.
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "http://test/?product=firefox-latest&os=osx&lang=en-US&kind=typo", nil)
+	assert.NoError(t, err)
+
+	bouncerHandler.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+}
+
+func TestBouncerHandlerKindPrintJSON(t *testing.T) {
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "http://test/?product=firefox-latest-ssl&os=win&lang=en-US&kind=checksums&print=json", nil)
+	assert.NoError(t, err)
+
+	bouncerHandler.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.JSONEq(t, `{"url":"https://download-installer.cdn.mozilla.net/pub/firefox/releases/39.0/SHA256SUMS","product":"Firefox-SSL","os":"win","lang":"en-US","kind":"checksums","ssl_only":true,"attribution":false}`, w.Body.String())
+
+	// The metadata of the installer doesn't apply to other kinds.
+	w = httptest.NewRecorder()
+	req, err = http.NewRequest("GET", "http://test/?product=firefox-latest-ssl&os=win&lang=en-US&kind=checksums&print=meta", nil)
+	assert.NoError(t, err)
+
+	bouncerHandler.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.NotContains(t, w.Body.String(), "sha256")
+
+}
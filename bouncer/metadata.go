@@ -0,0 +1,69 @@
+package bouncer
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"log"
+	"net/http"
+)
+
+// metadata returns the metadata of a resolved build. Only the Resolution is
+// set when nothing is known about the build.
+func (r *Resolver) metadata(res *Resolution, pinHTTPS bool) (*Metadata, error) {
+	metadata := &Metadata{Resolution: res}
+	if res.LocationID == "" {
+		return metadata, nil
+	}
+
+	locationMetadata, err := r.db.MetadataFor(res.LocationID, res.Lang)
+	switch {
+	case err == sql.ErrNoRows:
+		return metadata, nil
+	case err != nil:
+		return nil, err
+	}
+
+	metadata.SHA256 = locationMetadata.SHA256
+	metadata.Size = locationMetadata.Size
+	metadata.Version = locationMetadata.Version
+
+	if locationMetadata.SignaturePath != "" {
+		signatureURL, err := r.locationURL(r.db.BaseURLsFor, pinHTTPS, res.Lang, res.Product, res.SSLOnly, locationMetadata.SignaturePath)
+		if err == nil {
+			err = validateRedirectURL(signatureURL, r.redirectHosts())
+		}
+		if err != nil {
+			log.Printf("Invalid signature path for location %s: %v", res.LocationID, err)
+		} else {
+			metadata.SignatureURL = signatureURL
+		}
+	}
+	return metadata, nil
+}
+
+// setDigestHeaders sets the Repr-Digest (RFC 9530) and Digest (RFC 3230)
+// headers when the SHA-256 of the build is known. The redirect is served
+// anyway when the metadata cannot be read.
+func (b *BouncerHandler) setDigestHeaders(w http.ResponseWriter, res *Resolution) {
+	if res.LocationID == "" {
+		return
+	}
+
+	metadata, err := b.db.MetadataFor(res.LocationID, res.Lang)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("Could not read metadata for location %s: %v", res.LocationID, err)
+		}
+		return
+	}
+
+	sum, err := hex.DecodeString(metadata.SHA256)
+	if err != nil || len(sum) != 32 {
+		return
+	}
+
+	digest := base64.StdEncoding.EncodeToString(sum)
+	w.Header().Set("Repr-Digest", "sha-256=:"+digest+":")
+	w.Header().Set("Digest", "SHA-256="+digest)
+}
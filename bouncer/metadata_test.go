@@ -0,0 +1,85 @@
+package bouncer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBouncerHandlerPrintMeta(t *testing.T) {
+	tests := []struct {
+		URL      string
+		Expected string
+	}{
+		{
+			"http://test/?product=firefox-latest-ssl&os=win&lang=en-US&print=meta",
+			`{"url":"https://download-installer.cdn.mozilla.net/pub/firefox/releases/39.0/win32/en-US/Firefox%20Setup%2039.0.exe","product":"Firefox-SSL","os":"win","lang":"en-US","ssl_only":true,"attribution":false,"sha256":"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08","size":49542368,"version":"39.0","signature_url":"https://download-installer.cdn.mozilla.net/pub/firefox/releases/39.0/win32/en-US/Firefox%20Setup%2039.0.exe.asc"}`,
+		},
+		{
+			"http://test/?product=firefox-latest-ssl&os=win&lang=en-GB&print=meta",
+			`{"url":"https://download-installer.cdn.mozilla.net/pub/firefox/releases/39.0/win32/en-GB/Firefox%20Setup%2039.0.exe","product":"Firefox-SSL","os":"win","lang":"en-GB","ssl_only":true,"attribution":false,"version":"39.0","signature_url":"https://download-installer.cdn.mozilla.net/pub/firefox/releases/39.0/win32/en-GB/Firefox%20Setup%2039.0.exe.asc"}`,
+		},
+		{
+			// Without metadata.
+			"http://test/?product=firefox-latest&os=osx&lang=en-US&print=meta",
+			`{"url":"http://download.cdn.mozilla.net/pub/firefox/releases/39.0/mac/en-US/Firefox%2039.0.dmg","product":"Firefox","os":"osx","lang":"en-US","ssl_only":false,"attribution":false}`,
+		},
+		{
+			// Attribution is ignored.
+			"http://test/?product=firefox-latest-ssl&os=win&lang=en-US&attribution_code=att-code&attribution_sig=anhmacsig&print=meta",
+			`{"url":"https://download-installer.cdn.mozilla.net/pub/firefox/releases/39.0/win32/en-US/Firefox%20Setup%2039.0.exe","product":"Firefox-SSL","os":"win","lang":"en-US","ssl_only":true,"attribution":false,"sha256":"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08","size":49542368,"version":"39.0","signature_url":"https://download-installer.cdn.mozilla.net/pub/firefox/releases/39.0/win32/en-US/Firefox%20Setup%2039.0.exe.asc"}`,
+		},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", test.URL, nil)
+		assert.NoError(t, err)
+
+		bouncerHandler.ServeHTTP(w, req)
+		assert.Equal(t, 200, w.Code, "url: %v", test.URL)
+		assert.Equal(t, "application/json", w.Result().Header.Get("Content-Type"), "url: %v", test.URL)
+		assert.JSONEq(t, test.Expected, w.Body.String(), "url: %v", test.URL)
+	}
+}
+
+func TestBouncerHandlerDigestHeaders(t *testing.T) {
+	h := *bouncerHandler
+	h.DigestHeaders = true
+
+	tests := []struct {
+		URL        string
+		ReprDigest string
+		Digest     string
+	}{
+		{
+			"http://test/?product=firefox-latest-ssl&os=win&lang=en-US",
+			"sha-256=:n4bQgYhMfWWaL+qgxVrQFaO/TxsrC4Is0V1sFbDwCgg=:",
+			"SHA-256=n4bQgYhMfWWaL+qgxVrQFaO/TxsrC4Is0V1sFbDwCgg=",
+		},
+		// The metadata of this language has no SHA-256.
+		{"http://test/?product=firefox-latest-ssl&os=win&lang=en-GB", "", ""},
+		// No metadata.
+		{"http://test/?product=firefox-latest&os=osx&lang=en-US", "", ""},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", test.URL, nil)
+		assert.NoError(t, err)
+
+		h.ServeHTTP(w, req)
+		assert.Equal(t, 302, w.Code, "url: %v", test.URL)
+		assert.Equal(t, test.ReprDigest, w.Result().Header.Get("Repr-Digest"), "url: %v", test.URL)
+		assert.Equal(t, test.Digest, w.Result().Header.Get("Digest"), "url: %v", test.URL)
+	}
+
+	// Disabled by default.
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", tests[0].URL, nil)
+	assert.NoError(t, err)
+	bouncerHandler.ServeHTTP(w, req)
+	assert.Empty(t, w.Result().Header.Get("Repr-Digest"))
+}
@@ -0,0 +1,135 @@
+package bouncer
+
+import (
+	"database/sql"
+	"encoding/xml"
+	"log"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+)
+
+// metalinkNamespace is the XML namespace of Metalink 4 documents (RFC 5854).
+const metalinkNamespace = "urn:ietf:params:xml:ns:metalink"
+
+type metalinkDocument struct {
+	XMLName xml.Name       `xml:"metalink"`
+	XMLNS   string         `xml:"xmlns,attr"`
+	Files   []metalinkFile `xml:"file"`
+}
+
+type metalinkFile struct {
+	Name     string        `xml:"name,attr"`
+	Version  string        `xml:"version,omitempty"`
+	Language string        `xml:"language,omitempty"`
+	OS       string        `xml:"os,omitempty"`
+	Size     int64         `xml:"size,omitempty"`
+	Hash     *metalinkHash `xml:"hash,omitempty"`
+	URLs     []metalinkURL `xml:"url"`
+}
+
+type metalinkHash struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type metalinkURL struct {
+	// Priority is 1 for the preferred URL, then increases.
+	Priority int    `xml:"priority,attr"`
+	URL      string `xml:",chardata"`
+}
+
+// metalink returns a Metalink 4 document listing the URLs of a resolved build
+// on all the base URLs that serve it, along with its size and hash when they
+// are known.
+func (r *Resolver) metalink(res *Resolution, pinHTTPS bool) ([]byte, error) {
+	urls, err := r.mirrorURLs(res, pinHTTPS)
+	if err != nil {
+		return nil, err
+	}
+
+	file := metalinkFile{
+		Name:     metalinkFileName(res.URL),
+		Language: res.Lang,
+		OS:       res.OS,
+	}
+	for i, mirrorURL := range urls {
+		file.URLs = append(file.URLs, metalinkURL{Priority: i + 1, URL: mirrorURL})
+	}
+
+	if res.LocationID != "" {
+		metadata, err := r.db.MetadataFor(res.LocationID, res.Lang)
+		switch {
+		case err == sql.ErrNoRows:
+		case err != nil:
+			return nil, err
+		default:
+			file.Version = metadata.Version
+			file.Size = metadata.Size
+			if metadata.SHA256 != "" {
+				file.Hash = &metalinkHash{Type: "sha-256", Value: metadata.SHA256}
+			}
+		}
+	}
+
+	doc, err := xml.MarshalIndent(&metalinkDocument{
+		XMLNS: metalinkNamespace,
+		Files: []metalinkFile{file},
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), doc...), nil
+}
+
+// mirrorURLs returns the URLs of a resolved build, starting with the URL that
+// bouncer redirects to, followed by the URLs on the base URLs of the product:
+// its own when it has a mapping, the pinned ones otherwise. HTTP URLs are
+// only listed when HTTPS isn't required.
+func (r *Resolver) mirrorURLs(res *Resolution, pinHTTPS bool) ([]string, error) {
+	urls := []string{res.URL}
+
+	locationPath := strings.Replace(res.LocationPath, ":lang", res.Lang, -1)
+	if locationPath == "" || isAbsoluteLocation(locationPath) {
+		return urls, nil
+	}
+
+	// Products with a base URL mapping are only on their own bases, like
+	// when bouncer redirects to them.
+	baseURLHttp, baseURLHttps, err := r.baseURLs(r.db.BaseURLsFor, res.Product)
+	if err != nil {
+		return nil, err
+	}
+
+	baseURLs := []string{}
+	if baseURLHttps != "" {
+		baseURLs = append(baseURLs, "https://"+baseURLHttps)
+	}
+	if !pinHTTPS && !res.SSLOnly && baseURLHttp != "" {
+		baseURLs = append(baseURLs, "http://"+baseURLHttp)
+	}
+
+	allowedHosts := r.redirectHosts()
+	for _, baseURL := range baseURLs {
+		mirrorURL := baseURL + locationPath
+		if slices.Contains(urls, mirrorURL) {
+			continue
+		}
+		if err := validateRedirectURL(mirrorURL, allowedHosts); err != nil {
+			log.Printf("Leaving %q out of the metalink: %v", mirrorURL, err)
+			continue
+		}
+		urls = append(urls, mirrorURL)
+	}
+	return urls, nil
+}
+
+// metalinkFileName returns the name of the file behind a URL.
+func metalinkFileName(fileURL string) string {
+	u, err := url.Parse(fileURL)
+	if err != nil {
+		return ""
+	}
+	return path.Base(u.Path)
+}
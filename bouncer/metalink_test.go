@@ -0,0 +1,88 @@
+package bouncer
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBouncerHandlerPrintMetalink(t *testing.T) {
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "http://test/?product=firefox-latest-ssl&os=win&lang=en-US&print=metalink", nil)
+	assert.NoError(t, err)
+
+	bouncerHandler.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "application/metalink4+xml", w.Result().Header.Get("Content-Type"))
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<metalink xmlns="urn:ietf:params:xml:ns:metalink">
+  <file name="Firefox Setup 39.0.exe">
+    <version>39.0</version>
+    <language>en-US</language>
+    <os>win</os>
+    <size>49542368</size>
+    <hash type="sha-256">9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08</hash>
+    <url priority="1">https://download-installer.cdn.mozilla.net/pub/firefox/releases/39.0/win32/en-US/Firefox%20Setup%2039.0.exe</url>
+  </file>
+</metalink>`, w.Body.String())
+}
+
+func TestBouncerHandlerPrintMetalinkURLs(t *testing.T) {
+	tests := []struct {
+		URL      string
+		Pin      bool
+		Expected []metalinkURL
+	}{
+		{
+			"http://test/?product=firefox-latest&os=osx&lang=en-US&print=metalink",
+			false,
+			[]metalinkURL{
+				{1, "http://download.cdn.mozilla.net/pub/firefox/releases/39.0/mac/en-US/Firefox%2039.0.dmg"},
+				{2, "https://download-installer.cdn.mozilla.net/pub/firefox/releases/39.0/mac/en-US/Firefox%2039.0.dmg"},
+			},
+		},
+		{
+			"http://test/?product=firefox-latest&os=osx&lang=en-US&print=metalink",
+			true,
+			[]metalinkURL{
+				{1, "https://download-installer.cdn.mozilla.net/pub/firefox/releases/39.0/mac/en-US/Firefox%2039.0.dmg"},
+			},
+		},
+		{
+			"http://test/?product=Thunderbird-131.0.1-SSL&os=win&lang=en-US&print=metalink",
+			false,
+			[]metalinkURL{
+				// Not on the pinned base URLs.
+				{1, "https://download-installer.cdn.thunderbird.net/pub/thunderbird/releases/131.0.1/win32/en-US/Thunderbird%20Setup%20131.0.1.exe"},
+			},
+		},
+		{
+			// Absolute locations have a single URL.
+			"http://test/?product=firefox-store-latest-ssl&os=win64&lang=en-US&print=metalink",
+			false,
+			[]metalinkURL{
+				{1, "https://apps.microsoft.com/detail/9nzvdkpmr9rd?hl=en-US"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", test.URL, nil)
+		assert.NoError(t, err)
+		if test.Pin {
+			req.Header.Set("X-Forwarded-Proto", "https")
+		}
+
+		bouncerHandler.ServeHTTP(w, req)
+		assert.Equal(t, 200, w.Code, "url: %v", test.URL)
+
+		var doc metalinkDocument
+		assert.NoError(t, xml.Unmarshal(w.Body.Bytes(), &doc), "url: %v", test.URL)
+		assert.Len(t, doc.Files, 1, "url: %v", test.URL)
+		assert.Equal(t, test.Expected, doc.Files[0].URLs, "url: %v", test.URL)
+	}
+}
@@ -0,0 +1,30 @@
+package bouncer
+
+import (
+	"expvar"
+	"net/http"
+)
+
+var (
+	// metrics holds the application counters, e.g. rejected redirects.
+	metrics = expvar.NewMap("bouncer")
+	// rolloutMetrics counts the requests of rollouts, by alias and resolved
+	// product, e.g. firefox-latest/Firefox-127.0.
+	rolloutMetrics = new(expvar.Map)
+	// experimentMetrics counts the requests of running experiments, by base
+	// product, experiment and resolved product, e.g.
+	// firefox-stub/137/Firefox-137.0-funnelcake137.
+	experimentMetrics = new(expvar.Map)
+)
+
+func init() {
+	metrics.Set("rollouts", rolloutMetrics)
+	metrics.Set("experiments", experimentMetrics)
+}
+
+// MetricsHandler returns the application counters as JSON. Unlike
+// expvar.Handler, it doesn't expose the command line or memory stats.
+func MetricsHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(metrics.String()))
+}
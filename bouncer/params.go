@@ -0,0 +1,76 @@
+package bouncer
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Values of the print param.
+const (
+	printYes      = "yes"
+	printJSON     = "json"
+	printMeta     = "meta"
+	printMetalink = "metalink"
+)
+
+// BouncerParams holds/parses params for incoming bouncer requests
+type BouncerParams struct {
+	// Print is the value of the print param, e.g. printYes to print the URL
+	// instead of redirecting to it.
+	Print   string
+	OS      string
+	Product string
+	Lang    string
+	// Kind is the kind of resource requested, e.g. KindSignature. An empty
+	// value is the same as KindInstaller.
+	Kind            string
+	AttributionCode string
+	AttributionSig  string
+	Referer         string
+	UserAgent       string
+	// GPC is set when the request has the Global Privacy Control signal
+	// (Sec-GPC: 1).
+	GPC bool
+	// DNT is set when the request has the Do Not Track signal (DNT: 1).
+	DNT bool
+	// AsOf is the time at which aliases are resolved, for print requests that
+	// explain past resolutions. The zero value means now.
+	AsOf time.Time
+	// RolloutKey is the key that the client is bucketed by for rollouts. It
+	// is only set for HTTP requests: gRPC requests have no key, and are never
+	// part of a rollout.
+	RolloutKey string
+	// Experiment is the value of the funnelcake param, the ID of the
+	// experiment that the request is part of.
+	Experiment string
+}
+
+// BouncerParamsFromValues constructs parameter list from incoming request Values
+func BouncerParamsFromValues(vals url.Values, headers http.Header) *BouncerParams {
+	return &BouncerParams{
+		Print:           vals.Get("print"),
+		OS:              strings.TrimSpace(strings.ToLower(vals.Get("os"))),
+		Product:         strings.TrimSpace(strings.ToLower(vals.Get("product"))),
+		Lang:            vals.Get("lang"),
+		Kind:            strings.TrimSpace(strings.ToLower(vals.Get("kind"))),
+		AttributionCode: vals.Get("attribution_code"),
+		AttributionSig:  vals.Get("attribution_sig"),
+		Referer:         headers.Get("Referer"),
+		UserAgent:       headers.Get("User-Agent"),
+		GPC:             headers.Get("Sec-GPC") == "1",
+		DNT:             headers.Get("DNT") == "1",
+		Experiment:      strings.TrimSpace(vals.Get("funnelcake")),
+	}
+}
+
+// parseAsOf parses the as_of param, an RFC 3339 timestamp. The zero time is
+// returned when it isn't set.
+func parseAsOf(vals url.Values) (time.Time, error) {
+	value := vals.Get("as_of")
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
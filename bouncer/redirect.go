@@ -0,0 +1,61 @@
+package bouncer
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// validateRedirectURL checks that rawURL is safe to redirect to: it must be an
+// http(s) URL without credentials, control characters or path traversal, and
+// its host must be one of allowedHosts.
+func validateRedirectURL(rawURL string, allowedHosts []string) error {
+	if strings.IndexFunc(rawURL, isControlCharacter) != -1 {
+		return errors.New("URL contains control characters")
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("scheme %q is not allowed", u.Scheme)
+	}
+
+	if u.User != nil {
+		return errors.New("URL contains credentials")
+	}
+
+	if !hostAllowed(u.Host, allowedHosts) {
+		return fmt.Errorf("host %q is not allowed", u.Host)
+	}
+
+	if strings.Contains(u.Path, `\`) {
+		return errors.New("path contains a backslash")
+	}
+	for _, segment := range strings.Split(u.Path, "/") {
+		if segment == ".." {
+			return errors.New("path contains a parent directory reference")
+		}
+	}
+
+	return nil
+}
+
+func hostAllowed(host string, allowedHosts []string) bool {
+	for _, allowed := range allowedHosts {
+		if allowed != "" && strings.EqualFold(host, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// baseURLHost returns the host of a base URL, which excludes the scheme, e.g.
+// download.cdn.mozilla.net/pub.
+func baseURLHost(baseURL string) string {
+	host, _, _ := strings.Cut(baseURL, "/")
+	return host
+}
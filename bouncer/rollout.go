@@ -0,0 +1,291 @@
+package bouncer
+
+import (
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// rolloutBuckets is the number of buckets that clients are split into,
+	// so that rollout targets get a percentage of them.
+	rolloutBuckets = 100
+	// maxRolloutTargets is the maximum number of targets of a rollout.
+	maxRolloutTargets = 10
+	// rolloutAliasesCacheTime is how long the aliases with a rollout are
+	// cached, i.e. how long rollouts changed by other instances take to
+	// apply.
+	rolloutAliasesCacheTime = 10 * time.Second
+)
+
+// rolloutAliases caches the aliases that have a rollout, so that requests of
+// the other aliases don't query mirror_alias_rollouts.
+type rolloutAliases struct {
+	mu sync.Mutex
+	// aliases are the names of the aliases, keyed by lowercased name.
+	aliases   map[string]string
+	expiresAt time.Time
+}
+
+// RolloutTarget is a product that an alias points to for a percentage of the
+// clients.
+type RolloutTarget struct {
+	Product string `json:"product"`
+	Percent int    `json:"percent"`
+}
+
+// Rollout is the weighted targets of an alias. Clients that aren't in the
+// buckets of a target get the product that the alias points to.
+type Rollout struct {
+	Alias   string          `json:"alias"`
+	Targets []RolloutTarget `json:"targets"`
+}
+
+// Rollout returns the rollout of an alias, or sql.ErrNoRows when it has none.
+func (d *DB) Rollout(alias string) (*Rollout, error) {
+	targets, err := rolloutTargets(d, alias)
+	if err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &Rollout{Alias: alias, Targets: targets}, nil
+}
+
+// PutRollout replaces the targets of the rollout of an alias, after running
+// the preflight check of a switch from the product that the alias points to
+// to each target. It returns the changes for the clients of each target.
+func (r *Resolver) PutRollout(actor string, rollout *Rollout) ([]AliasChange, error) {
+	if len(rollout.Targets) == 0 || len(rollout.Targets) > maxRolloutTargets {
+		return nil, fmt.Errorf("%w: a rollout must have between 1 and %d targets", ErrInvalid, maxRolloutTargets)
+	}
+	total := 0
+	for i, target := range rollout.Targets {
+		if target.Percent < 1 || target.Percent > rolloutBuckets {
+			return nil, fmt.Errorf("%w: the percent of %s must be between 1 and %d", ErrInvalid, target.Product, rolloutBuckets)
+		}
+		for _, other := range rollout.Targets[:i] {
+			if strings.EqualFold(other.Product, target.Product) {
+				return nil, fmt.Errorf("%w: product %s is targeted twice", ErrInvalid, target.Product)
+			}
+		}
+		total += target.Percent
+	}
+	if total > rolloutBuckets {
+		return nil, fmt.Errorf("%w: the percents add up to more than %d", ErrInvalid, rolloutBuckets)
+	}
+
+	var changes []AliasChange
+	err := r.db.inTx(func(tx *sql.Tx) error {
+		base, err := currentAlias(tx, rollout.Alias)
+		if err != nil {
+			return err
+		}
+		if base == "" {
+			return fmt.Errorf("%w: unknown alias %s", ErrInvalid, rollout.Alias)
+		}
+
+		problems := []string{}
+		changes = make([]AliasChange, 0, len(rollout.Targets))
+		for _, target := range rollout.Targets {
+			if strings.EqualFold(target.Product, base) {
+				return fmt.Errorf("%w: alias %s already points to %s", ErrInvalid, rollout.Alias, target.Product)
+			}
+			s := &AliasSwitch{Aliases: map[string]string{rollout.Alias: target.Product}}
+			targetChanges, targetProblems, err := r.preflightAliasSwitch(tx, s, []string{rollout.Alias}, time.Now().UTC())
+			if err != nil {
+				return err
+			}
+			problems = append(problems, targetProblems...)
+			changes = append(changes, targetChanges...)
+		}
+		if len(problems) > 0 {
+			return &PreflightError{Problems: problems}
+		}
+
+		old, err := rolloutTargets(tx, rollout.Alias)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM mirror_alias_rollouts WHERE alias = ?", rollout.Alias); err != nil {
+			return err
+		}
+		for _, target := range rollout.Targets {
+			_, err := tx.Exec(
+				"INSERT INTO mirror_alias_rollouts (alias, product, percent) VALUES (?, ?, ?)",
+				rollout.Alias, target.Product, target.Percent)
+			if err != nil {
+				return err
+			}
+		}
+		return audit(tx, actor, AuditActionPut, AuditResourceRollout, rollout.Alias, rolloutAuditValue(rollout.Alias, old), rollout)
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.db.resetRolloutAliases()
+	return changes, nil
+}
+
+// DeleteRollout deletes the rollout of an alias, so that all clients get the
+// product that the alias points to.
+func (d *DB) DeleteRollout(actor, alias string) error {
+	err := d.inTx(func(tx *sql.Tx) error {
+		old, err := rolloutTargets(tx, alias)
+		if err != nil {
+			return err
+		}
+		if len(old) == 0 {
+			return sql.ErrNoRows
+		}
+
+		if _, err := tx.Exec("DELETE FROM mirror_alias_rollouts WHERE alias = ?", alias); err != nil {
+			return err
+		}
+		return audit(tx, actor, AuditActionDelete, AuditResourceRollout, alias, rolloutAuditValue(alias, old), nil)
+	})
+	if err == nil {
+		d.resetRolloutAliases()
+	}
+	return err
+}
+
+// rolloutProduct returns the bucket of the client in the rollout of a product,
+// along with the target that the bucket belongs to, if any. A nil bucket is
+// returned when the product has no rollout, for gRPC requests, which have no
+// key, and when the request explains a past resolution.
+func (r *Resolver) rolloutProduct(product string, reqParams *BouncerParams) (*RolloutBucket, string, error) {
+	if reqParams.RolloutKey == "" || !reqParams.AsOf.IsZero() {
+		return nil, "", nil
+	}
+
+	alias, err := r.db.rolloutAlias(product)
+	if err != nil || alias == "" {
+		return nil, "", err
+	}
+	targets, err := rolloutTargets(r.db, alias)
+	if err != nil || len(targets) == 0 {
+		return nil, "", err
+	}
+
+	bucket := &RolloutBucket{Alias: alias, Bucket: rolloutBucket(alias, reqParams.RolloutKey)}
+	upper := 0
+	for _, target := range targets {
+		upper += target.Percent
+		if bucket.Bucket < upper {
+			return bucket, target.Product, nil
+		}
+	}
+	return bucket, "", nil
+}
+
+// rolloutKey returns the key that clients are bucketed by: the value of
+// RolloutKeyHeader, or else the IP address and user agent of the client.
+func (r *Resolver) rolloutKey(req *http.Request) string {
+	if r.RolloutKeyHeader != "" {
+		if key := req.Header.Get(r.RolloutKeyHeader); key != "" {
+			return key
+		}
+	}
+	return clientIP(req) + " " + req.Header.Get("User-Agent")
+}
+
+// clientIP returns the IP address of the client: the first address of
+// X-Forwarded-For behind a proxy, or else the address of the connection.
+func clientIP(req *http.Request) string {
+	if forwarded := req.Header.Get("X-Forwarded-For"); forwarded != "" {
+		ip, _, _ := strings.Cut(forwarded, ",")
+		return strings.TrimSpace(ip)
+	}
+
+	ip, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		ip = req.RemoteAddr
+	}
+	return ip
+}
+
+// rolloutBucket hashes a client key into a bucket. The alias is part of the
+// hash, so that the clients of a rollout aren't always the first ones of the
+// next.
+func rolloutBucket(alias, key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(strings.ToLower(alias)))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	return int(h.Sum32() % rolloutBuckets)
+}
+
+// rolloutAlias returns the name of an alias, as stored in
+// mirror_alias_rollouts, if it had a rollout at most rolloutAliasesCacheTime
+// ago or since the last change made through d, or "".
+func (d *DB) rolloutAlias(alias string) (string, error) {
+	d.rollouts.mu.Lock()
+	defer d.rollouts.mu.Unlock()
+
+	if d.rollouts.aliases == nil || !time.Now().Before(d.rollouts.expiresAt) {
+		rows, err := d.Query("SELECT DISTINCT alias FROM mirror_alias_rollouts")
+		if err != nil {
+			return "", err
+		}
+		defer rows.Close()
+
+		aliases := map[string]string{}
+		for rows.Next() {
+			var alias string
+			if err := rows.Scan(&alias); err != nil {
+				return "", err
+			}
+			aliases[strings.ToLower(alias)] = alias
+		}
+		if err := rows.Err(); err != nil {
+			return "", err
+		}
+		d.rollouts.aliases = aliases
+		d.rollouts.expiresAt = time.Now().Add(rolloutAliasesCacheTime)
+	}
+	return d.rollouts.aliases[strings.ToLower(alias)], nil
+}
+
+// resetRolloutAliases makes the next rolloutAlias reload the aliases with a
+// rollout.
+func (d *DB) resetRolloutAliases() {
+	d.rollouts.mu.Lock()
+	defer d.rollouts.mu.Unlock()
+	d.rollouts.aliases = nil
+}
+
+func rolloutTargets(q queryer, alias string) ([]RolloutTarget, error) {
+	rows, err := q.Query(
+		`SELECT product, percent FROM mirror_alias_rollouts
+			WHERE alias = ?
+			ORDER BY id`,
+		alias)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	targets := []RolloutTarget{}
+	for rows.Next() {
+		var target RolloutTarget
+		if err := rows.Scan(&target.Product, &target.Percent); err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	return targets, rows.Err()
+}
+
+func rolloutAuditValue(alias string, targets []RolloutTarget) any {
+	if len(targets) == 0 {
+		return nil
+	}
+	return &Rollout{Alias: alias, Targets: targets}
+}
@@ -0,0 +1,228 @@
+package bouncer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRolloutBucket(t *testing.T) {
+	assert.Equal(t, rolloutBucket("firefox-latest", "client"), rolloutBucket("Firefox-Latest", "client"))
+	assert.NotEqual(t, rolloutBucket("firefox-latest", "client"), rolloutBucket("firefox-beta-latest", "client"))
+
+	// Clients are spread evenly.
+	counts := make([]int, rolloutBuckets)
+	for i := 0; i < 100000; i++ {
+		counts[rolloutBucket("firefox-latest", fmt.Sprintf("client-%d", i))]++
+	}
+	for bucket, count := range counts {
+		assert.InDelta(t, 1000, count, 150, "bucket %d", bucket)
+	}
+}
+
+func TestRolloutKey(t *testing.T) {
+	r := &Resolver{}
+	req := httptest.NewRequest("GET", "http://test/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("User-Agent", "Firefox")
+	assert.Equal(t, "10.0.0.1 Firefox", r.rolloutKey(req))
+
+	// Behind a proxy, clients are told apart by X-Forwarded-For.
+	req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.2")
+	assert.Equal(t, "203.0.113.7 Firefox", r.rolloutKey(req))
+
+	r.RolloutKeyHeader = "X-Rollout-Key"
+	assert.Equal(t, "203.0.113.7 Firefox", r.rolloutKey(req))
+	req.Header.Set("X-Rollout-Key", "client")
+	assert.Equal(t, "client", r.rolloutKey(req))
+}
+
+func TestRolloutAlias(t *testing.T) {
+	testDB.resetRolloutAliases()
+	alias, err := testDB.rolloutAlias("firefox-latest")
+	assert.NoError(t, err)
+	assert.Empty(t, alias)
+
+	_, err = testDB.Exec("INSERT INTO mirror_alias_rollouts (alias, product, percent) VALUES ('firefox-latest', 'Firefox-127.0', 10)")
+	assert.NoError(t, err)
+	defer func() {
+		_, err := testDB.Exec("DELETE FROM mirror_alias_rollouts WHERE alias = 'firefox-latest'")
+		assert.NoError(t, err)
+		testDB.resetRolloutAliases()
+	}()
+
+	// Rollouts added by other instances apply once the cache expires.
+	alias, err = testDB.rolloutAlias("firefox-latest")
+	assert.NoError(t, err)
+	assert.Empty(t, alias)
+
+	testDB.resetRolloutAliases()
+	alias, err = testDB.rolloutAlias("Firefox-Latest")
+	assert.NoError(t, err)
+	assert.Equal(t, "firefox-latest", alias)
+}
+
+// rolloutTestKey returns a client key whose bucket is in [min, max).
+func rolloutTestKey(alias string, min, max int) string {
+	for i := 0; ; i++ {
+		key := fmt.Sprintf("client-%d", i)
+		if bucket := rolloutBucket(alias, key); bucket >= min && bucket < max {
+			return key
+		}
+	}
+}
+
+func TestBouncerHandlerRollout(t *testing.T) {
+	// Buckets and metrics use the name of the alias as stored, whatever the
+	// case of the request.
+	_, err := testDB.PutAlias("test", "Firefox-Rollout-Test", "Firefox")
+	assert.NoError(t, err)
+	defer func() {
+		_, err := testDB.Exec("DELETE FROM mirror_alias_rollouts WHERE alias = 'firefox-rollout-test'")
+		assert.NoError(t, err)
+		assert.NoError(t, testDB.DeleteAlias("test", "firefox-rollout-test"))
+	}()
+	_, err = bouncerHandler.PutRollout("test", &Rollout{
+		Alias:   "Firefox-Rollout-Test",
+		Targets: []RolloutTarget{{Product: "Firefox-127.0", Percent: 25}},
+	})
+	assert.NoError(t, err)
+
+	h := *bouncerHandler
+	h.RolloutKeyHeader = "X-Rollout-Key"
+	h.CacheTime = 10 * time.Minute
+	request := func(url, key string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", url, nil)
+		assert.NoError(t, err)
+		req.Header.Set("X-Rollout-Key", key)
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	targetKey := rolloutTestKey("firefox-rollout-test", 0, 25)
+	baseKey := rolloutTestKey("firefox-rollout-test", 25, 100)
+	for _, tc := range []struct {
+		key      string
+		location string
+	}{
+		{targetKey, "http://download.cdn.mozilla.net/pub/firefox/releases/127.0/mac/en-US/Firefox%20Setup%20127.0.exe"},
+		{baseKey, "http://download.cdn.mozilla.net/pub/firefox/releases/39.0/mac/en-US/Firefox%2039.0.dmg"},
+	} {
+		// Retries get the same build.
+		for i := 0; i < 2; i++ {
+			w := request("http://test/?product=firefox-rollout-test&os=osx&lang=en-US", tc.key)
+			assert.Equal(t, 302, w.Code)
+			assert.Equal(t, tc.location, w.Result().Header.Get("Location"))
+			assert.Equal(t, fmt.Sprintf("Firefox-Rollout-Test/%d", rolloutBucket("firefox-rollout-test", tc.key)), w.Result().Header.Get("X-Rollout-Bucket"))
+			assert.Equal(t, "private, max-age=600", w.Result().Header.Get("Cache-Control"))
+			assert.Equal(t, "X-Rollout-Key", w.Result().Header.Get("Vary"))
+		}
+	}
+	assert.NotNil(t, rolloutMetrics.Get("Firefox-Rollout-Test/Firefox-127.0"))
+	assert.NotNil(t, rolloutMetrics.Get("Firefox-Rollout-Test/Firefox"))
+
+	w := request("http://test/?product=firefox-rollout-test&os=osx&lang=en-US&print=json", targetKey)
+	var res Resolution
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, "Firefox-127.0", res.Product)
+	assert.Equal(t, &RolloutBucket{Alias: "Firefox-Rollout-Test", Bucket: rolloutBucket("firefox-rollout-test", targetKey)}, res.Rollout)
+
+	// Past resolutions are not part of the rollout.
+	w = request("http://test/?product=firefox-rollout-test&os=osx&lang=en-US&print=yes&as_of=2030-01-01T00:00:00Z", targetKey)
+	assert.Equal(t, "http://download.cdn.mozilla.net/pub/firefox/releases/39.0/mac/en-US/Firefox%2039.0.dmg", w.Body.String())
+	assert.Empty(t, w.Result().Header.Get("X-Rollout-Bucket"))
+
+	// Requests without a key, like gRPC requests and batch items, are not part
+	// of the rollout.
+	keyless, err := h.Resolve(&BouncerParams{Product: "firefox-rollout-test", OS: "osx", Lang: "en-US"}, nil, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "Firefox", keyless.Product)
+	assert.Nil(t, keyless.Rollout)
+	results, err := h.ResolveBatch([]BatchItem{{Product: "firefox-rollout-test", OS: "osx", Lang: "en-US"}}, "", "", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "Firefox", results[0].Product)
+	assert.Nil(t, results[0].Rollout)
+
+	// Aliases without rollout are cacheable.
+	w = request("http://test/?product=firefox-latest&os=osx&lang=en-US", targetKey)
+	assert.Equal(t, "max-age=600", w.Result().Header.Get("Cache-Control"))
+	assert.Empty(t, w.Result().Header.Get("X-Rollout-Bucket"))
+
+	// Clients of a target that isn't available in an OS get the product of
+	// the alias.
+	_, err = testDB.Exec("UPDATE mirror_alias_rollouts SET product = 'Firefox-nightly-latest' WHERE alias = 'firefox-rollout-test'")
+	assert.NoError(t, err)
+	w = request("http://test/?product=firefox-rollout-test&os=osx&lang=en-US", targetKey)
+	assert.Equal(t, "http://download.cdn.mozilla.net/pub/firefox/releases/39.0/mac/en-US/Firefox%2039.0.dmg", w.Result().Header.Get("Location"))
+}
+
+func TestAdminHandlerRollout(t *testing.T) {
+	_, err := testDB.PutAlias("test", "firefox-rollout-admin-test", "Firefox")
+	assert.NoError(t, err)
+	defer func() {
+		_, err := testDB.Exec("DELETE FROM mirror_alias_rollouts WHERE alias = 'firefox-rollout-admin-test'")
+		assert.NoError(t, err)
+		assert.NoError(t, testDB.DeleteAlias("test", "firefox-rollout-admin-test"))
+	}()
+
+	h := newTestAdminHandler()
+	path := "/admin/v1/aliases/firefox-rollout-admin-test/rollout"
+
+	for _, tc := range []struct {
+		path string
+		body string
+		code int
+	}{
+		{path, `{"targets": []}`, 400},
+		{path, `{"targets": [{"product": "Firefox-127.0", "percent": 0}]}`, 400},
+		{path, `{"targets": [{"product": "Firefox-127.0", "percent": 60}, {"product": "Firefox-127.0b9", "percent": 50}]}`, 400},
+		{path, `{"targets": [{"product": "Firefox-127.0", "percent": 10}, {"product": "firefox-127.0", "percent": 10}]}`, 400},
+		{path, `{"targets": [{"product": "Firefox", "percent": 10}]}`, 400},
+		{"/admin/v1/aliases/unknown-alias/rollout", `{"targets": [{"product": "Firefox-127.0", "percent": 10}]}`, 400},
+		{path, `{"targets": [{"product": "Firefox-nightly-latest", "percent": 10}]}`, 422},
+		{path, `{"targets": [{"product": "unknown-product", "percent": 10}]}`, 422},
+	} {
+		w := adminRequest(h, "PUT", tc.path, "releng-token", tc.body)
+		assert.Equal(t, tc.code, w.Code, tc.body)
+	}
+
+	w := adminRequest(h, "GET", path, "releng-token", "")
+	assert.Equal(t, 404, w.Code)
+
+	w = adminRequest(h, "PUT", path, "releng-token", `{"targets": [{"product": "Firefox-127.0", "percent": 10}]}`)
+	assert.Equal(t, 200, w.Code)
+	var resp RolloutResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Len(t, resp.Changes, 1)
+	assert.Equal(t, "Firefox-127.0", resp.Changes[0].NewProduct)
+
+	w = adminRequest(h, "PUT", path, "releng-token", `{"targets": [{"product": "Firefox-127.0", "percent": 25}, {"product": "Firefox-127.0b9", "percent": 5}]}`)
+	assert.Equal(t, 200, w.Code)
+	w = adminRequest(h, "GET", path, "releng-token", "")
+	assert.Equal(t, 200, w.Code)
+	assert.JSONEq(t, `{"alias": "firefox-rollout-admin-test", "targets": [{"product": "Firefox-127.0", "percent": 25}, {"product": "Firefox-127.0b9", "percent": 5}]}`, w.Body.String())
+
+	entries, err := testDB.AuditLog(1, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, AuditResourceRollout, entries[0].Resource)
+	assert.JSONEq(t, `{"alias": "firefox-rollout-admin-test", "targets": [{"product": "Firefox-127.0", "percent": 10}]}`, string(entries[0].Old))
+
+	// Aliases and products of a rollout can't be deleted.
+	w = adminRequest(h, "DELETE", "/admin/v1/aliases/firefox-rollout-admin-test", "releng-token", "")
+	assert.Equal(t, 409, w.Code)
+	w = adminRequest(h, "DELETE", "/admin/v1/products/Firefox-127.0b9", "releng-token", "")
+	assert.Equal(t, 409, w.Code)
+
+	w = adminRequest(h, "DELETE", path, "auditor-token", "")
+	assert.Equal(t, 403, w.Code)
+	w = adminRequest(h, "DELETE", path, "releng-token", "")
+	assert.Equal(t, 204, w.Code)
+	w = adminRequest(h, "DELETE", path, "releng-token", "")
+	assert.Equal(t, 404, w.Code)
+}
@@ -0,0 +1,44 @@
+package bouncer
+
+import (
+	"net/http"
+	"strings"
+)
+
+// latestChannelProducts maps the channels of /latest/{channel}/{os} to
+// products.
+var latestChannelProducts = map[string]string{
+	"release":    "firefox-latest-ssl",
+	"beta":       "firefox-beta-latest-ssl",
+	"devedition": "firefox-devedition-latest-ssl",
+	"nightly":    "firefox-nightly-latest-ssl",
+	"esr":        "firefox-esr-latest-ssl",
+}
+
+// ServeDownloadPath serves /download/{product}/{os}/{lang} like
+// /?product={product}&os={os}&lang={lang}. Other params, e.g. print, are read
+// from the query string.
+func (b *BouncerHandler) ServeDownloadPath(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	query.Set("product", req.PathValue("product"))
+	query.Set("os", req.PathValue("os"))
+	query.Set("lang", req.PathValue("lang"))
+	b.serve(w, req, query)
+}
+
+// ServeLatestPath serves /latest/{channel}/{os} like
+// /?product={product}&os={os}, where the product is the latest Firefox of the
+// channel (see latestChannelProducts). Other params, e.g. lang, are read from
+// the query string.
+func (b *BouncerHandler) ServeLatestPath(w http.ResponseWriter, req *http.Request) {
+	product, ok := latestChannelProducts[strings.ToLower(req.PathValue("channel"))]
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+
+	query := req.URL.Query()
+	query.Set("product", product)
+	query.Set("os", req.PathValue("os"))
+	b.serve(w, req, query)
+}
@@ -0,0 +1,107 @@
+package bouncer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBouncerHandlerDownloadPath(t *testing.T) {
+	mux := http.NewServeMux()
+	bouncerHandler.RegisterRoutes(mux)
+
+	tests := []struct {
+		URL              string
+		UserAgent        string
+		ExpectedCode     int
+		ExpectedLocation string
+		ExpectedBody     string
+	}{
+		{
+			URL:              "http://test/download/firefox-latest/osx/en-US",
+			ExpectedCode:     302,
+			ExpectedLocation: "http://download.cdn.mozilla.net/pub/firefox/releases/39.0/mac/en-US/Firefox%2039.0.dmg",
+		},
+		{
+			// Path params take precedence over query params.
+			URL:              "http://test/download/Firefox-Latest/OSX/en-US?product=other&os=win&lang=fr",
+			ExpectedCode:     302,
+			ExpectedLocation: "http://download.cdn.mozilla.net/pub/firefox/releases/39.0/mac/en-US/Firefox%2039.0.dmg",
+		},
+		{
+			// Overrides apply.
+			URL:              "http://test/download/firefox-stub/win/en-US",
+			UserAgent:        "Mozilla/5.0 (Windows NT 6.1; WOW64; Trident/7.0; rv:11.0) like Gecko",
+			ExpectedCode:     302,
+			ExpectedLocation: "https://download-installer.cdn.mozilla.net/pub/firefox/releases/115.16.1esr/win64/en-US/Firefox%20Setup%20115.16.1esr.exe",
+		},
+		{
+			URL:          "http://test/download/firefox-latest/osx/en-US?print=yes",
+			ExpectedCode: 200,
+			ExpectedBody: "http://download.cdn.mozilla.net/pub/firefox/releases/39.0/mac/en-US/Firefox%2039.0.dmg",
+		},
+		{
+			URL:          "http://test/download/unknown-product/osx/en-US",
+			ExpectedCode: 404,
+		},
+		{
+			// Incomplete paths aren't download paths.
+			URL:              "http://test/download/firefox-latest/osx",
+			ExpectedCode:     302,
+			ExpectedLocation: "https://www.mozilla.org/",
+		},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", test.URL, nil)
+		assert.NoError(t, err)
+		req.Header.Set("User-Agent", test.UserAgent)
+
+		mux.ServeHTTP(w, req)
+		assert.Equal(t, test.ExpectedCode, w.Code, "url: %v", test.URL)
+		assert.Equal(t, test.ExpectedLocation, w.Result().Header.Get("Location"), "url: %v", test.URL)
+		if test.ExpectedBody != "" {
+			assert.Equal(t, test.ExpectedBody, w.Body.String(), "url: %v", test.URL)
+		}
+	}
+}
+
+func TestBouncerHandlerLatestPath(t *testing.T) {
+	mux := http.NewServeMux()
+	bouncerHandler.RegisterRoutes(mux)
+
+	tests := []struct {
+		URL              string
+		ExpectedCode     int
+		ExpectedLocation string
+	}{
+		{
+			"http://test/latest/release/win",
+			302,
+			"https://download-installer.cdn.mozilla.net/pub/firefox/releases/39.0/win32/en-US/Firefox%20Setup%2039.0.exe",
+		},
+		{
+			"http://test/latest/Release/osx?lang=en-GB",
+			302,
+			"https://download-installer.cdn.mozilla.net/pub/firefox/releases/39.0/mac/en-GB/Firefox%2039.0.dmg",
+		},
+		{
+			"http://test/latest/unknown/win",
+			404,
+			"",
+		},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", test.URL, nil)
+		assert.NoError(t, err)
+
+		mux.ServeHTTP(w, req)
+		assert.Equal(t, test.ExpectedCode, w.Code, "url: %v", test.URL)
+		assert.Equal(t, test.ExpectedLocation, w.Result().Header.Get("Location"), "url: %v", test.URL)
+	}
+}
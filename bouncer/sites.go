@@ -0,0 +1,78 @@
+package bouncer
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Policies that can be granted to trusted sites.
+const (
+	// SitePolicyAttribution allows referrals from the site to be attributed
+	// when an attribution policy requires an allowed referrer, e.g. RTAMO.
+	SitePolicyAttribution = "attribution"
+	// SitePolicyESRExemption exempts referrals from the site from the ESR115
+	// override for Windows 7/8/8.1 clients.
+	SitePolicyESRExemption = "esr-exemption"
+	// SitePolicyCORS allows the site to read ?print=json responses
+	// cross-origin.
+	SitePolicyCORS = "cors"
+)
+
+var (
+	knownSitePolicies = []string{SitePolicyAttribution, SitePolicyESRExemption, SitePolicyCORS}
+
+	// defaultTrustedSites are used when no trusted sites are configured.
+	defaultTrustedSites = TrustedSites{
+		{Origin: "https://www.mozilla.org", Policies: []string{SitePolicyAttribution, SitePolicyESRExemption, SitePolicyCORS}},
+		{Origin: "https://www.firefox.com", Policies: []string{SitePolicyAttribution, SitePolicyESRExemption, SitePolicyCORS}},
+	}
+)
+
+// TrustedSite is a first-party site along with the policies that apply to
+// requests it refers.
+type TrustedSite struct {
+	// Origin is the scheme and host of the site, e.g. https://www.mozilla.org
+	Origin   string   `json:"origin"`
+	Policies []string `json:"policies"`
+}
+
+func (s *TrustedSite) validate() error {
+	u, err := url.Parse(s.Origin)
+	if err != nil {
+		return fmt.Errorf("trusted site %s: %v", s.Origin, err)
+	}
+	if u.Scheme == "" || u.Host == "" || u.Path != "" || u.RawQuery != "" {
+		return fmt.Errorf("trusted site %s: origin must be a scheme and a host", s.Origin)
+	}
+	for _, policy := range s.Policies {
+		if !slices.Contains(knownSitePolicies, policy) {
+			return fmt.Errorf("trusted site %s: unknown policy %s", s.Origin, policy)
+		}
+	}
+	return nil
+}
+
+// matches returns whether a URL, e.g. a referrer, belongs to the site.
+func (s *TrustedSite) matches(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return strings.EqualFold(u.Scheme+"://"+u.Host, s.Origin)
+}
+
+// TrustedSites is a registry of trusted first-party sites.
+type TrustedSites []TrustedSite
+
+// Allows returns whether the site that referrer belongs to is trusted for a
+// policy.
+func (sites TrustedSites) Allows(referrer, policy string) bool {
+	for _, site := range sites {
+		if site.matches(referrer) && slices.Contains(site.Policies, policy) {
+			return true
+		}
+	}
+	return false
+}
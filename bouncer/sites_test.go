@@ -0,0 +1,43 @@
+package bouncer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrustedSitesAllows(t *testing.T) {
+	sites := TrustedSites{
+		{Origin: "https://www.mozilla.org", Policies: []string{SitePolicyAttribution, SitePolicyESRExemption}},
+		{Origin: "https://www.allizom.org", Policies: []string{SitePolicyAttribution}},
+	}
+
+	tests := []struct {
+		Referrer string
+		Policy   string
+		Allowed  bool
+	}{
+		{"https://www.mozilla.org/", SitePolicyAttribution, true},
+		{"https://www.mozilla.org/fr/firefox/new/", SitePolicyESRExemption, true},
+		{"https://WWW.MOZILLA.ORG/", SitePolicyAttribution, true},
+		{"https://www.allizom.org/", SitePolicyAttribution, true},
+		{"https://www.allizom.org/", SitePolicyESRExemption, false},
+		{"http://www.mozilla.org/", SitePolicyAttribution, false},
+		{"https://www-mozilla.org/", SitePolicyAttribution, false},
+		{"https://www.mozilla.org.example.com/", SitePolicyAttribution, false},
+		{"https://www.mozilla.org:8443/", SitePolicyAttribution, false},
+		{"https://example.com/https://www.mozilla.org/", SitePolicyAttribution, false},
+		{"", SitePolicyAttribution, false},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.Allowed, sites.Allows(test.Referrer, test.Policy), "referrer: %s, policy: %s", test.Referrer, test.Policy)
+	}
+}
+
+func TestTrustedSiteValidate(t *testing.T) {
+	assert.NoError(t, (&TrustedSite{Origin: "https://www.mozilla.org", Policies: []string{SitePolicyAttribution}}).validate())
+	assert.Error(t, (&TrustedSite{Origin: "https://www.mozilla.org/", Policies: []string{SitePolicyAttribution}}).validate())
+	assert.Error(t, (&TrustedSite{Origin: "www.mozilla.org", Policies: []string{SitePolicyAttribution}}).validate())
+	assert.Error(t, (&TrustedSite{Origin: "https://www.mozilla.org", Policies: []string{"unknown"}}).validate())
+}
@@ -0,0 +1,114 @@
+package bouncer
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// StubBackend is a stub attribution service along with the requests that it
+// handles.
+type StubBackend struct {
+	Name    string `json:"name"`
+	RootURL string `json:"root_url"`
+	// OSes are the OSes eligible for this backend.
+	OSes []string `json:"oses"`
+	// Products, when set, restricts this backend to products containing one
+	// of these substrings.
+	Products []string `json:"products"`
+	// ExcludedProducts are substrings of products that are never sent to this
+	// backend, in addition to defaultExcludedProducts.
+	ExcludedProducts []string `json:"excluded_products"`
+	// ForwardParams are the extra query params forwarded to this backend, in
+	// addition to the ones that are always sent.
+	ForwardParams []string `json:"forward_params"`
+	// HealthURL is used to check the health of this backend. It defaults to
+	// __lbheartbeat__ relative to RootURL.
+	HealthURL string `json:"health_url"`
+}
+
+// defaultExcludedProducts are never sent to any backend, in addition to the
+// ExcludedProducts of each backend: updates, MSI, and MSIX installers.
+// Technically, -msi covers -msix as well, but both are here to prevent a
+// future footgun where -msi is removed, but we still need -msix covered.
+var defaultExcludedProducts = []string{"-partial", "-complete", "-msi", "-msix"}
+
+// defaultStubBackend returns the backend used when no backends are configured.
+func defaultStubBackend(rootURL string) StubBackend {
+	return StubBackend{
+		Name:    "default",
+		RootURL: rootURL,
+		OSes:    []string{"win", "win64", "win64-aarch64", "osx"},
+	}
+}
+
+func (s *StubBackend) validate() error {
+	if s.Name == "" {
+		return errors.New("stub backend has no name")
+	}
+	u, err := url.Parse(s.RootURL)
+	if err != nil {
+		return fmt.Errorf("stub backend %s: %v", s.Name, err)
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return fmt.Errorf("stub backend %s: root_url must be an absolute http(s) URL", s.Name)
+	}
+	if len(s.OSes) == 0 {
+		return fmt.Errorf("stub backend %s has no OSes", s.Name)
+	}
+	return nil
+}
+
+// Handles returns whether a request for this os and product is eligible for
+// this backend.
+func (s *StubBackend) Handles(os, product string) bool {
+	if !slices.Contains(s.OSes, os) {
+		return false
+	}
+
+	if len(s.Products) > 0 && !containsAnySubstring(product, s.Products) {
+		return false
+	}
+
+	return !containsAnySubstring(product, defaultExcludedProducts) &&
+		!containsAnySubstring(product, s.ExcludedProducts)
+}
+
+// URL returns the stub attribution URL for a request. The params are merged
+// into the query of RootURL, if any. It returns "" when RootURL can't be
+// parsed, which is never a valid redirect.
+func (s *StubBackend) URL(reqParams *BouncerParams, reqQuery url.Values) string {
+	u, err := url.Parse(s.RootURL)
+	if err != nil {
+		return ""
+	}
+
+	query := u.Query()
+	for _, name := range s.ForwardParams {
+		if value := reqQuery.Get(name); value != "" {
+			query.Set(name, value)
+		}
+	}
+
+	// These are set last so that they can't be overridden by the forwarded
+	// params.
+	query.Set("lang", reqParams.Lang)
+	query.Set("os", reqParams.OS)
+	query.Set("product", reqParams.Product)
+	query.Set("attribution_code", reqParams.AttributionCode)
+	query.Set("attribution_sig", reqParams.AttributionSig)
+
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+func containsAnySubstring(s string, substrings []string) bool {
+	for _, substring := range substrings {
+		if strings.Contains(s, substring) {
+			return true
+		}
+	}
+	return false
+}
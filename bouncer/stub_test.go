@@ -0,0 +1,72 @@
+package bouncer
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStubBackendHandles(t *testing.T) {
+	backend := defaultStubBackend("https://stub/")
+	assert.True(t, backend.Handles("win", "firefox-stub"))
+	assert.True(t, backend.Handles("osx", "firefox-latest-ssl"))
+	assert.False(t, backend.Handles("linux64", "firefox-latest-ssl"))
+	assert.False(t, backend.Handles("win64", "firefox-msi-latest-ssl"))
+	assert.False(t, backend.Handles("win", "firefox-115.17.0esr-partial-115.16.1esr"))
+
+	backend = StubBackend{
+		Name:     "dmg",
+		RootURL:  "https://dmg-stub/",
+		OSes:     []string{"osx"},
+		Products: []string{"firefox-latest", "firefox-beta-latest"},
+	}
+	assert.True(t, backend.Handles("osx", "firefox-latest-ssl"))
+	assert.True(t, backend.Handles("osx", "firefox-beta-latest-ssl"))
+	assert.False(t, backend.Handles("osx", "firefox-nightly-latest-ssl"))
+	assert.False(t, backend.Handles("win", "firefox-latest-ssl"))
+	// Updates and MSI installers are never attributed, even without
+	// excluded_products.
+	assert.False(t, backend.Handles("osx", "firefox-latest-msi-ssl"))
+	assert.False(t, backend.Handles("osx", "firefox-latest-partial-127.0"))
+}
+
+func TestStubBackendURL(t *testing.T) {
+	backend := StubBackend{
+		Name:          "test",
+		RootURL:       "https://stub/",
+		OSes:          []string{"win"},
+		ForwardParams: []string{"funnelcake", "product"},
+	}
+	params := &BouncerParams{
+		OS:              "win",
+		Product:         "firefox-stub",
+		Lang:            "en-US",
+		AttributionCode: "att-code",
+		AttributionSig:  "att-sig",
+	}
+	query := url.Values{
+		"funnelcake": {"137"},
+		"product":    {"overridden"},
+		"other":      {"ignored"},
+	}
+
+	assert.Equal(t,
+		"https://stub/?attribution_code=att-code&attribution_sig=att-sig&funnelcake=137&lang=en-US&os=win&product=firefox-stub",
+		backend.URL(params, query),
+	)
+
+	// The params are merged into the query of the root URL.
+	backend.RootURL = "https://stub/?channel=release&os=ignored"
+	assert.Equal(t,
+		"https://stub/?attribution_code=att-code&attribution_sig=att-sig&channel=release&funnelcake=137&lang=en-US&os=win&product=firefox-stub",
+		backend.URL(params, query),
+	)
+}
+
+func TestStubBackendValidate(t *testing.T) {
+	assert.NoError(t, (&StubBackend{Name: "test", RootURL: "https://stub/", OSes: []string{"win"}}).validate())
+	assert.Error(t, (&StubBackend{RootURL: "https://stub/", OSes: []string{"win"}}).validate())
+	assert.Error(t, (&StubBackend{Name: "test", RootURL: "stub/", OSes: []string{"win"}}).validate())
+	assert.Error(t, (&StubBackend{Name: "test", RootURL: "https://stub/"}).validate())
+}
@@ -0,0 +1,125 @@
+package bouncer
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// stubUnhealthyAfter is the number of consecutive failed checks after which
+// a backend is considered unhealthy, so that a single slow response doesn't
+// disable attribution.
+const stubUnhealthyAfter = 3
+
+// StubHealthChecker periodically checks the health of the stub attribution
+// backends. Backends are considered healthy until stubUnhealthyAfter
+// consecutive checks fail, and healthy again as soon as a check succeeds.
+type StubHealthChecker struct {
+	backends []StubBackend
+	client   *http.Client
+	interval time.Duration
+
+	mu sync.RWMutex
+	// failures is the number of consecutive failed checks of each backend.
+	failures map[string]int
+}
+
+// NewStubHealthChecker returns a checker for the given backends. Each check
+// times out after timeout.
+func NewStubHealthChecker(backends []StubBackend, interval, timeout time.Duration) *StubHealthChecker {
+	return &StubHealthChecker{
+		backends: backends,
+		client: &http.Client{
+			Timeout: timeout,
+			// A redirect is not a healthy response.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		interval: interval,
+		failures: map[string]int{},
+	}
+}
+
+// Start checks the backends every interval, in the background.
+func (c *StubHealthChecker) Start() {
+	go func() {
+		for {
+			c.checkAll()
+			time.Sleep(c.interval)
+		}
+	}()
+}
+
+func (c *StubHealthChecker) checkAll() {
+	for _, backend := range c.backends {
+		err := c.check(&backend)
+
+		c.mu.Lock()
+		failures := c.failures[backend.Name]
+		if err != nil {
+			c.failures[backend.Name] = failures + 1
+		} else {
+			c.failures[backend.Name] = 0
+		}
+		c.mu.Unlock()
+
+		// Only changes of state are logged.
+		switch {
+		case err != nil && failures+1 == stubUnhealthyAfter:
+			log.Printf("Stub attribution backend %s is unhealthy after %d failed checks: %v", backend.Name, stubUnhealthyAfter, err)
+		case err == nil && failures >= stubUnhealthyAfter:
+			log.Printf("Stub attribution backend %s is healthy again", backend.Name)
+		}
+	}
+}
+
+func (c *StubHealthChecker) check(backend *StubBackend) error {
+	resp, err := c.client.Get(backend.healthURL())
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Healthy returns whether a backend is healthy. A nil checker considers all
+// backends healthy.
+func (c *StubHealthChecker) Healthy(name string) bool {
+	if c == nil {
+		return true
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.failures[name] < stubUnhealthyAfter
+}
+
+// Status returns the health of each backend.
+func (c *StubHealthChecker) Status() map[string]bool {
+	status := map[string]bool{}
+	for _, backend := range c.backends {
+		status[backend.Name] = c.Healthy(backend.Name)
+	}
+	return status
+}
+
+// healthURL returns the URL used to check the health of the backend. It
+// defaults to the Dockerflow __lbheartbeat__ endpoint next to the root URL.
+func (s *StubBackend) healthURL() string {
+	if s.HealthURL != "" {
+		return s.HealthURL
+	}
+
+	root, err := url.Parse(s.RootURL)
+	if err != nil {
+		return s.RootURL
+	}
+	return root.ResolveReference(&url.URL{Path: "__lbheartbeat__"}).String()
+}
@@ -0,0 +1,64 @@
+package bouncer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStubHealthChecker(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusOK)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/__lbheartbeat__", r.URL.Path)
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer server.Close()
+
+	checker := NewStubHealthChecker([]StubBackend{
+		{Name: "up", RootURL: server.URL + "/"},
+		{Name: "down", RootURL: "http://127.0.0.1:1/"},
+	}, time.Minute, time.Second)
+
+	// Backends are healthy until they have been checked.
+	assert.True(t, checker.Healthy("up"))
+	assert.True(t, checker.Healthy("down"))
+
+	checker.checkAll()
+	assert.True(t, checker.Healthy("up"))
+	// A single failed check doesn't make a backend unhealthy.
+	assert.True(t, checker.Healthy("down"))
+	for i := 1; i < stubUnhealthyAfter; i++ {
+		checker.checkAll()
+	}
+	assert.True(t, checker.Healthy("up"))
+	assert.False(t, checker.Healthy("down"))
+	assert.Equal(t, map[string]bool{"up": true, "down": false}, checker.Status())
+
+	status.Store(http.StatusServiceUnavailable)
+	for i := 0; i < stubUnhealthyAfter; i++ {
+		assert.True(t, checker.Healthy("up"))
+		checker.checkAll()
+	}
+	assert.False(t, checker.Healthy("up"))
+
+	// One successful check makes it healthy again.
+	status.Store(http.StatusOK)
+	checker.checkAll()
+	assert.True(t, checker.Healthy("up"))
+
+	var nilChecker *StubHealthChecker
+	assert.True(t, nilChecker.Healthy("up"))
+}
+
+func TestStubBackendHealthURL(t *testing.T) {
+	backend := StubBackend{RootURL: "https://stubdownloader.services.mozilla.com/"}
+	assert.Equal(t, "https://stubdownloader.services.mozilla.com/__lbheartbeat__", backend.healthURL())
+
+	backend.HealthURL = "https://stubdownloader.services.mozilla.com/__heartbeat__"
+	assert.Equal(t, "https://stubdownloader.services.mozilla.com/__heartbeat__", backend.healthURL())
+}
@@ -0,0 +1,161 @@
+// Package bouncerapi holds the types of the JSON API of bouncer, shared by the
+// bouncer package and its client. It only depends on the standard library.
+package bouncerapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Kinds of resources that can be requested with the kind param.
+const (
+	// KindInstaller is the build itself, i.e. the path of the location.
+	KindInstaller = "installer"
+	// KindSignature is the detached GPG signature of the build.
+	KindSignature = "signature"
+	// KindChecksums is the SHA256SUMS file of the release.
+	KindChecksums = "checksums"
+	// KindReleaseNotes is the release notes page of the release.
+	KindReleaseNotes = "release-notes"
+)
+
+// Resolution describes where a request is sent, and why.
+type Resolution struct {
+	URL string `json:"url"`
+	// Product is the product after overrides and alias resolution. For
+	// attributed requests, it is the requested product.
+	Product string `json:"product"`
+	OS      string `json:"os"`
+	Lang    string `json:"lang"`
+	// Kind is the kind of resource, when it isn't the installer.
+	Kind    string `json:"kind,omitempty"`
+	SSLOnly bool   `json:"ssl_only"`
+	// Override is the name of the rule that changed the requested product,
+	// if any, e.g. esr115 or pre2024.
+	Override string `json:"override,omitempty"`
+	// Attribution is set when the request is sent to a stub attribution
+	// service.
+	Attribution bool `json:"attribution"`
+	// AsOf is the time at which aliases were resolved, when it isn't now.
+	AsOf *time.Time `json:"as_of,omitempty"`
+	// Rollout is the bucket of the client when the requested alias has a
+	// rollout.
+	Rollout *RolloutBucket `json:"rollout,omitempty"`
+	// Experiment is the running experiment that the request is part of, if
+	// any.
+	Experiment *ExperimentAssignment `json:"experiment,omitempty"`
+	// LocationID and LocationPath are the ID and path of the location, for
+	// unattributed requests. They are only known to the server.
+	LocationID   string `json:"-"`
+	LocationPath string `json:"-"`
+}
+
+// RolloutBucket is the bucket of a client in the rollout of an alias.
+type RolloutBucket struct {
+	Alias  string `json:"alias"`
+	Bucket int    `json:"bucket"`
+}
+
+// String returns the value of the X-Rollout-Bucket header, e.g.
+// firefox-latest/42.
+func (b *RolloutBucket) String() string {
+	return fmt.Sprintf("%s/%d", b.Alias, b.Bucket)
+}
+
+// ExperimentAssignment is the experiment that a request is part of.
+type ExperimentAssignment struct {
+	ID string `json:"id"`
+	// Variant is set when the request got the variant product, rather than
+	// the base product.
+	Variant bool `json:"variant"`
+	// Bucket is the bucket of the client, when the experiment has a traffic
+	// cap.
+	Bucket *int `json:"bucket,omitempty"`
+}
+
+// String returns the value of the X-Experiment-Bucket header, e.g. 137/42.
+func (a *ExperimentAssignment) String() string {
+	return fmt.Sprintf("%s/%d", a.ID, *a.Bucket)
+}
+
+// Metadata is a Resolution along with what is known about the build.
+type Metadata struct {
+	*Resolution
+	// SHA256 is the hex-encoded SHA-256 of the build.
+	SHA256       string `json:"sha256,omitempty"`
+	Size         int64  `json:"size,omitempty"`
+	Version      string `json:"version,omitempty"`
+	SignatureURL string `json:"signature_url,omitempty"`
+}
+
+// BatchItem is a product, OS and language to resolve. UserAgent is used for
+// the override rules, like the User-Agent header of a regular request.
+type BatchItem struct {
+	Product   string `json:"product"`
+	OS        string `json:"os"`
+	Lang      string `json:"lang"`
+	UserAgent string `json:"user_agent,omitempty"`
+}
+
+// BatchRequest is the body of a batch resolution request.
+type BatchRequest struct {
+	Items []BatchItem `json:"items"`
+}
+
+// BatchResult is the resolution of a BatchItem, or the reason why it could
+// not be resolved.
+type BatchResult struct {
+	*Resolution
+	Error string `json:"error,omitempty"`
+}
+
+// BatchResponse holds the results of a batch, in the order of the items.
+type BatchResponse struct {
+	Items []BatchResult `json:"items"`
+}
+
+// Page is a page of catalog items. Next is the URL of the next page, if any.
+type Page[T any] struct {
+	Items  []T    `json:"items"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+	Next   string `json:"next,omitempty"`
+}
+
+// Alias maps an alias to a product.
+type Alias struct {
+	Alias   string `json:"alias"`
+	Product string `json:"product"`
+}
+
+// Product is a product along with the languages and OSes it is available in.
+// A product without languages is available in all languages.
+type Product struct {
+	ID        string   `json:"-"`
+	Name      string   `json:"name"`
+	SSLOnly   bool     `json:"ssl_only"`
+	Languages []string `json:"languages"`
+	OSes      []string `json:"oses"`
+}
+
+// HealthResult represents service health
+type HealthResult struct {
+	DB      bool `json:"db"`
+	Healthy bool `json:"healthy"`
+	// Degraded is set when bouncer works but some features are disabled,
+	// e.g. when a stub attribution backend is unhealthy.
+	Degraded        bool            `json:"degraded,omitempty"`
+	StubAttribution map[string]bool `json:"stub_attribution,omitempty"`
+}
+
+// JSON returns json string
+func (h *HealthResult) JSON() []byte {
+	res, err := json.Marshal(h)
+	if err != nil {
+		log.Printf("HealthResult.JSON err: %v", err)
+		return []byte{}
+	}
+	return res
+}
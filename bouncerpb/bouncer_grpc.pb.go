@@ -0,0 +1,209 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: bouncer.proto
+
+package bouncerpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Bouncer_Resolve_FullMethodName      = "/bouncer.v1.Bouncer/Resolve"
+	Bouncer_BatchResolve_FullMethodName = "/bouncer.v1.Bouncer/BatchResolve"
+	Bouncer_ListProducts_FullMethodName = "/bouncer.v1.Bouncer/ListProducts"
+)
+
+// BouncerClient is the client API for Bouncer service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Bouncer resolves download URLs, like the HTTP interface of bouncer.
+type BouncerClient interface {
+	// Resolve returns where a client should be sent for a product, OS and
+	// language. It fails with NOT_FOUND when there is no such build.
+	Resolve(ctx context.Context, in *ResolveRequest, opts ...grpc.CallOption) (*ResolveResponse, error)
+	// BatchResolve resolves many items at once, with a result per item.
+	BatchResolve(ctx context.Context, in *BatchResolveRequest, opts ...grpc.CallOption) (*BatchResolveResponse, error)
+	// ListProducts lists the products, along with their languages and OSes.
+	ListProducts(ctx context.Context, in *ListProductsRequest, opts ...grpc.CallOption) (*ListProductsResponse, error)
+}
+
+type bouncerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBouncerClient(cc grpc.ClientConnInterface) BouncerClient {
+	return &bouncerClient{cc}
+}
+
+func (c *bouncerClient) Resolve(ctx context.Context, in *ResolveRequest, opts ...grpc.CallOption) (*ResolveResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResolveResponse)
+	err := c.cc.Invoke(ctx, Bouncer_Resolve_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bouncerClient) BatchResolve(ctx context.Context, in *BatchResolveRequest, opts ...grpc.CallOption) (*BatchResolveResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BatchResolveResponse)
+	err := c.cc.Invoke(ctx, Bouncer_BatchResolve_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bouncerClient) ListProducts(ctx context.Context, in *ListProductsRequest, opts ...grpc.CallOption) (*ListProductsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListProductsResponse)
+	err := c.cc.Invoke(ctx, Bouncer_ListProducts_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// BouncerServer is the server API for Bouncer service.
+// All implementations must embed UnimplementedBouncerServer
+// for forward compatibility.
+//
+// Bouncer resolves download URLs, like the HTTP interface of bouncer.
+type BouncerServer interface {
+	// Resolve returns where a client should be sent for a product, OS and
+	// language. It fails with NOT_FOUND when there is no such build.
+	Resolve(context.Context, *ResolveRequest) (*ResolveResponse, error)
+	// BatchResolve resolves many items at once, with a result per item.
+	BatchResolve(context.Context, *BatchResolveRequest) (*BatchResolveResponse, error)
+	// ListProducts lists the products, along with their languages and OSes.
+	ListProducts(context.Context, *ListProductsRequest) (*ListProductsResponse, error)
+	mustEmbedUnimplementedBouncerServer()
+}
+
+// UnimplementedBouncerServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedBouncerServer struct{}
+
+func (UnimplementedBouncerServer) Resolve(context.Context, *ResolveRequest) (*ResolveResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Resolve not implemented")
+}
+func (UnimplementedBouncerServer) BatchResolve(context.Context, *BatchResolveRequest) (*BatchResolveResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchResolve not implemented")
+}
+func (UnimplementedBouncerServer) ListProducts(context.Context, *ListProductsRequest) (*ListProductsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListProducts not implemented")
+}
+func (UnimplementedBouncerServer) mustEmbedUnimplementedBouncerServer() {}
+func (UnimplementedBouncerServer) testEmbeddedByValue()                 {}
+
+// UnsafeBouncerServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to BouncerServer will
+// result in compilation errors.
+type UnsafeBouncerServer interface {
+	mustEmbedUnimplementedBouncerServer()
+}
+
+func RegisterBouncerServer(s grpc.ServiceRegistrar, srv BouncerServer) {
+	// If the following call pancis, it indicates UnimplementedBouncerServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Bouncer_ServiceDesc, srv)
+}
+
+func _Bouncer_Resolve_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResolveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BouncerServer).Resolve(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Bouncer_Resolve_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BouncerServer).Resolve(ctx, req.(*ResolveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Bouncer_BatchResolve_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchResolveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BouncerServer).BatchResolve(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Bouncer_BatchResolve_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BouncerServer).BatchResolve(ctx, req.(*BatchResolveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Bouncer_ListProducts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListProductsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BouncerServer).ListProducts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Bouncer_ListProducts_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BouncerServer).ListProducts(ctx, req.(*ListProductsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Bouncer_ServiceDesc is the grpc.ServiceDesc for Bouncer service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Bouncer_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "bouncer.v1.Bouncer",
+	HandlerType: (*BouncerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Resolve",
+			Handler:    _Bouncer_Resolve_Handler,
+		},
+		{
+			MethodName: "BatchResolve",
+			Handler:    _Bouncer_BatchResolve_Handler,
+		},
+		{
+			MethodName: "ListProducts",
+			Handler:    _Bouncer_ListProducts_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "bouncer.proto",
+}
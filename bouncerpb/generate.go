@@ -0,0 +1,5 @@
+// Package bouncerpb holds the gRPC interface of bouncer, generated from
+// bouncer.proto.
+package bouncerpb
+
+//go:generate buf generate
@@ -0,0 +1,316 @@
+// Package client is a Go client for the HTTP interface of bouncer.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mozilla-services/go-bouncer/bouncerapi"
+)
+
+const (
+	defaultTimeout      = 10 * time.Second
+	defaultMaxRetries   = 2
+	defaultRetryBackoff = 100 * time.Millisecond
+
+	// maxErrorBodyLength is the maximum length of the body kept in a
+	// StatusError.
+	maxErrorBodyLength = 1024
+)
+
+// Client calls a bouncer instance. Its fields can be changed before the first
+// call.
+type Client struct {
+	// BaseURL is the URL of bouncer, e.g. https://download.mozilla.org/
+	BaseURL string
+	// HTTPClient is used for all requests. Its Timeout applies to each
+	// attempt. Redirects are never followed.
+	HTTPClient *http.Client
+	// MaxRetries is the number of times a request is retried after a network
+	// error or a 5xx response.
+	MaxRetries int
+	// RetryBackoff is the delay before the first retry. It doubles for each
+	// retry.
+	RetryBackoff time.Duration
+	// UserAgent is sent when a Request has no UserAgent.
+	UserAgent string
+}
+
+// New returns a Client for the bouncer instance at baseURL.
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL: baseURL,
+		HTTPClient: &http.Client{
+			Timeout: defaultTimeout,
+		},
+		MaxRetries:   defaultMaxRetries,
+		RetryBackoff: defaultRetryBackoff,
+		UserAgent:    "go-bouncer-client",
+	}
+}
+
+// Request is a download request. Empty fields are left to bouncer's defaults.
+type Request struct {
+	Product string
+	OS      string
+	Lang    string
+	// Kind is the kind of resource, e.g. bouncerapi.KindSignature.
+	Kind            string
+	AttributionCode string
+	AttributionSig  string
+	// UserAgent is sent as the User-Agent header, which the override rules
+	// depend on.
+	UserAgent string
+}
+
+func (r *Request) query(print string) url.Values {
+	query := url.Values{}
+	for name, value := range map[string]string{
+		"product":          r.Product,
+		"os":               r.OS,
+		"lang":             r.Lang,
+		"kind":             r.Kind,
+		"attribution_code": r.AttributionCode,
+		"attribution_sig":  r.AttributionSig,
+		"print":            print,
+	} {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	return query
+}
+
+// URL returns the URL that bouncer redirects the request to.
+func (c *Client) URL(ctx context.Context, req *Request) (string, error) {
+	resp, body, err := c.do(ctx, http.MethodGet, "/", req.query("yes"), nil, req.UserAgent)
+	if err != nil {
+		return "", err
+	}
+	// Attributed requests are redirected even with print=yes.
+	if resp.StatusCode == http.StatusFound {
+		return resp.Header.Get("Location"), nil
+	}
+	return string(body), nil
+}
+
+// Resolve returns how bouncer resolves the request (print=json).
+func (c *Client) Resolve(ctx context.Context, req *Request) (*bouncerapi.Resolution, error) {
+	res := &bouncerapi.Resolution{}
+	if err := c.getJSON(ctx, "/", req.query("json"), req.UserAgent, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Metadata returns what bouncer knows about the build of the request
+// (print=meta).
+func (c *Client) Metadata(ctx context.Context, req *Request) (*bouncerapi.Metadata, error) {
+	metadata := &bouncerapi.Metadata{}
+	if err := c.getJSON(ctx, "/", req.query("meta"), req.UserAgent, metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// Metalink returns the Metalink 4 document of the build of the request
+// (print=metalink).
+func (c *Client) Metalink(ctx context.Context, req *Request) ([]byte, error) {
+	_, body, err := c.do(ctx, http.MethodGet, "/", req.query("metalink"), nil, req.UserAgent)
+	return body, err
+}
+
+// ResolveBatch resolves many items at once. Items that cannot be resolved
+// have an Error instead of a Resolution.
+func (c *Client) ResolveBatch(ctx context.Context, items []bouncerapi.BatchItem) ([]bouncerapi.BatchResult, error) {
+	body, err := json.Marshal(&bouncerapi.BatchRequest{Items: items})
+	if err != nil {
+		return nil, err
+	}
+
+	_, respBody, err := c.do(ctx, http.MethodPost, "/api/v1/resolve", nil, body, "")
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &bouncerapi.BatchResponse{}
+	if err := json.Unmarshal(respBody, resp); err != nil {
+		return nil, fmt.Errorf("bouncer: invalid response: %w", err)
+	}
+	return resp.Items, nil
+}
+
+// Aliases returns a page of aliases.
+func (c *Client) Aliases(ctx context.Context, limit, offset int) (*bouncerapi.Page[bouncerapi.Alias], error) {
+	page := &bouncerapi.Page[bouncerapi.Alias]{}
+	if err := c.getJSON(ctx, "/api/v1/aliases", pageQuery(limit, offset), "", page); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// Products returns a page of products.
+func (c *Client) Products(ctx context.Context, limit, offset int) (*bouncerapi.Page[*bouncerapi.Product], error) {
+	page := &bouncerapi.Page[*bouncerapi.Product]{}
+	if err := c.getJSON(ctx, "/api/v1/products", pageQuery(limit, offset), "", page); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// Product returns a product, by name.
+func (c *Client) Product(ctx context.Context, name string) (*bouncerapi.Product, error) {
+	product := &bouncerapi.Product{}
+	if err := c.getJSON(ctx, "/api/v1/products/"+url.PathEscape(name), nil, "", product); err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+// Heartbeat returns the health of bouncer and its dependencies. An unhealthy
+// bouncer returns both the result and ErrUnhealthy.
+func (c *Client) Heartbeat(ctx context.Context) (*bouncerapi.HealthResult, error) {
+	_, body, err := c.do(ctx, http.MethodGet, "/__heartbeat__", nil, nil, "")
+
+	// Unhealthy instances respond with a 500 and a result.
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusInternalServerError {
+		body = []byte(statusErr.Body)
+	} else if err != nil {
+		return nil, err
+	}
+
+	result := &bouncerapi.HealthResult{}
+	if err := json.Unmarshal(body, result); err != nil {
+		return nil, fmt.Errorf("bouncer: invalid response: %w", err)
+	}
+	if !result.Healthy {
+		return result, ErrUnhealthy
+	}
+	return result, nil
+}
+
+// LBHeartbeat returns nil when bouncer is up.
+func (c *Client) LBHeartbeat(ctx context.Context) error {
+	_, _, err := c.do(ctx, http.MethodGet, "/__lbheartbeat__", nil, nil, "")
+	return err
+}
+
+func (c *Client) getJSON(ctx context.Context, path string, query url.Values, userAgent string, v any) error {
+	resp, body, err := c.do(ctx, http.MethodGet, path, query, nil, userAgent)
+	if err != nil {
+		return err
+	}
+	// Bouncer redirects requests it can't describe, e.g. without a product.
+	if resp.StatusCode != http.StatusOK {
+		return &StatusError{StatusCode: resp.StatusCode, Body: resp.Header.Get("Location")}
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("bouncer: invalid response: %w", err)
+	}
+	return nil
+}
+
+// do sends a request, with retries, and returns the response along with its
+// body. Responses other than 200 and 302 are returned as a *StatusError.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body []byte, userAgent string) (*http.Response, []byte, error) {
+	reqURL := strings.TrimSuffix(c.BaseURL, "/") + path
+	if len(query) > 0 {
+		reqURL += "?" + query.Encode()
+	}
+	if userAgent == "" {
+		userAgent = c.UserAgent
+	}
+
+	backoff := c.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		resp, respBody, err := c.doOnce(ctx, method, reqURL, body, userAgent)
+		if err == nil || attempt >= c.MaxRetries || ctx.Err() != nil || !retryable(err) {
+			return resp, respBody, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) doOnce(ctx context.Context, method, reqURL string, body []byte, userAgent string) (*http.Response, []byte, error) {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, bodyReader)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusFound:
+		return resp, respBody, nil
+	}
+	if len(respBody) > maxErrorBodyLength {
+		respBody = respBody[:maxErrorBodyLength]
+	}
+	return resp, nil, &StatusError{StatusCode: resp.StatusCode, Body: string(respBody)}
+}
+
+// httpClient returns a copy of HTTPClient that doesn't follow redirects.
+func (c *Client) httpClient() *http.Client {
+	client := http.Client{}
+	if c.HTTPClient != nil {
+		client = *c.HTTPClient
+	}
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return &client
+}
+
+// retryable returns whether a request that failed with err can be retried:
+// network errors, including timeouts, and server errors can be temporary.
+func retryable(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500
+	}
+	return true
+}
+
+func pageQuery(limit, offset int) url.Values {
+	query := url.Values{}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	if offset > 0 {
+		query.Set("offset", strconv.Itoa(offset))
+	}
+	return query
+}
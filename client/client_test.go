@@ -0,0 +1,203 @@
+package client
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mozilla-services/go-bouncer/bouncer"
+	"github.com/mozilla-services/go-bouncer/bouncerapi"
+	"github.com/stretchr/testify/assert"
+)
+
+var testServer *httptest.Server
+
+func TestMain(m *testing.M) {
+	db, err := bouncer.NewDB("root@tcp(127.0.0.1:3306)/bouncer_test")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	bouncerHandler := bouncer.NewBouncerHandler(db, bouncer.Options{
+		PinnedBaseURLHttp:  "download.cdn.mozilla.net/pub",
+		PinnedBaseURLHttps: "download-installer.cdn.mozilla.net/pub",
+	})
+	healthHandler := bouncer.NewHealthHandler(db, nil, 0)
+
+	mux := http.NewServeMux()
+	mux.Handle("/__lbheartbeat__", healthHandler)
+	mux.Handle("/__heartbeat__", healthHandler)
+	bouncerHandler.RegisterRoutes(mux)
+
+	testServer = httptest.NewServer(mux)
+	defer testServer.Close()
+
+	os.Exit(m.Run())
+}
+
+func TestURL(t *testing.T) {
+	c := New(testServer.URL)
+
+	url, err := c.URL(context.Background(), &Request{Product: "firefox-latest", OS: "osx", Lang: "en-US"})
+	assert.NoError(t, err)
+	assert.Equal(t, "http://download.cdn.mozilla.net/pub/firefox/releases/39.0/mac/en-US/Firefox%2039.0.dmg", url)
+
+	_, err = c.URL(context.Background(), &Request{Product: "unknown-product", OS: "osx", Lang: "en-US"})
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestResolve(t *testing.T) {
+	c := New(testServer.URL)
+
+	res, err := c.Resolve(context.Background(), &Request{
+		Product:   "firefox-stub",
+		OS:        "win",
+		Lang:      "en-US",
+		UserAgent: "Mozilla/5.0 (Windows NT 6.1; WOW64; Trident/7.0; rv:11.0) like Gecko",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://download-installer.cdn.mozilla.net/pub/firefox/releases/115.16.1esr/win64/en-US/Firefox%20Setup%20115.16.1esr.exe", res.URL)
+	assert.NotEmpty(t, res.Override)
+
+	_, err = c.Resolve(context.Background(), &Request{Product: "unknown-product"})
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Requests without a product are redirected to mozilla.org.
+	_, err = c.Resolve(context.Background(), &Request{})
+	var statusErr *StatusError
+	assert.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusFound, statusErr.StatusCode)
+}
+
+func TestMetadata(t *testing.T) {
+	c := New(testServer.URL)
+
+	metadata, err := c.Metadata(context.Background(), &Request{Product: "firefox-latest-ssl", OS: "win", Lang: "en-US"})
+	assert.NoError(t, err)
+	assert.Equal(t, "39.0", metadata.Version)
+	assert.Equal(t, int64(49542368), metadata.Size)
+}
+
+func TestProducts(t *testing.T) {
+	c := New(testServer.URL)
+
+	page, err := c.Products(context.Background(), 1, 0)
+	assert.NoError(t, err)
+	assert.Len(t, page.Items, 1)
+
+	product, err := c.Product(context.Background(), "Firefox")
+	assert.NoError(t, err)
+	assert.Equal(t, "Firefox", product.Name)
+
+	_, err = c.Product(context.Background(), "unknown-product")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestResolveBatch(t *testing.T) {
+	c := New(testServer.URL)
+
+	results, err := c.ResolveBatch(context.Background(), []bouncerapi.BatchItem{
+		{Product: "firefox-latest", OS: "osx", Lang: "en-US"},
+		{Product: "unknown-product", OS: "osx", Lang: "en-US"},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.Equal(t, "http://download.cdn.mozilla.net/pub/firefox/releases/39.0/mac/en-US/Firefox%2039.0.dmg", results[0].URL)
+	assert.Equal(t, "not found", results[1].Error)
+}
+
+func TestHeartbeat(t *testing.T) {
+	c := New(testServer.URL)
+
+	result, err := c.Heartbeat(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, result.Healthy)
+
+	assert.NoError(t, c.LBHeartbeat(context.Background()))
+}
+
+func TestUnhealthy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"db":false,"healthy":false}`))
+	}))
+	defer server.Close()
+
+	c := New(server.URL)
+	c.MaxRetries = 0
+
+	result, err := c.Heartbeat(context.Background())
+	assert.ErrorIs(t, err, ErrUnhealthy)
+	assert.False(t, result.DB)
+}
+
+func TestRetries(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if calls.Add(1) < 3 {
+			http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("https://example.com/"))
+	}))
+	defer server.Close()
+
+	c := New(server.URL)
+	c.RetryBackoff = time.Millisecond
+
+	url, err := c.URL(context.Background(), &Request{Product: "firefox-latest"})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/", url)
+	assert.Equal(t, int32(3), calls.Load())
+
+	// Server errors are returned once the retries are exhausted.
+	calls.Store(0)
+	c.MaxRetries = 1
+	_, err = c.URL(context.Background(), &Request{Product: "firefox-latest"})
+	assert.ErrorIs(t, err, ErrServer)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestNoRetryOnClientError(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	c := New(server.URL)
+	_, err := c.URL(context.Background(), &Request{Product: "firefox-latest"})
+	assert.ErrorIs(t, err, ErrBadRequest)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	c := New(server.URL)
+	c.HTTPClient.Timeout = 10 * time.Millisecond
+	c.MaxRetries = 0
+
+	_, err := c.URL(context.Background(), &Request{Product: "firefox-latest"})
+	assert.Error(t, err)
+
+	// The context bounds all the attempts.
+	c.MaxRetries = 10
+	c.RetryBackoff = time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = c.URL(ctx, &Request{Product: "firefox-latest"})
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
@@ -0,0 +1,45 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrNotFound is returned when bouncer has nothing for a request, e.g. an
+	// unknown product.
+	ErrNotFound = errors.New("bouncer: not found")
+	// ErrBadRequest is returned when bouncer rejects the params of a request.
+	ErrBadRequest = errors.New("bouncer: bad request")
+	// ErrServer is returned when bouncer fails, e.g. because its database is
+	// down.
+	ErrServer = errors.New("bouncer: server error")
+	// ErrUnhealthy is returned by Heartbeat when bouncer is unhealthy.
+	ErrUnhealthy = errors.New("bouncer: unhealthy")
+)
+
+// StatusError is returned for unexpected HTTP statuses. It wraps ErrNotFound,
+// ErrBadRequest or ErrServer depending on the status, so that callers can use
+// errors.Is.
+type StatusError struct {
+	StatusCode int
+	// Body is the beginning of the response body.
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("bouncer: unexpected status %d: %s", e.StatusCode, e.Body)
+}
+
+func (e *StatusError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case e.StatusCode >= 500:
+		return ErrServer
+	case e.StatusCode >= 400:
+		return ErrBadRequest
+	}
+	return nil
+}
@@ -77,6 +77,111 @@ CREATE TABLE `mirror_products` (
 ) ENGINE=InnoDB AUTO_INCREMENT=4556 DEFAULT CHARSET=utf8;
 /*!40101 SET character_set_client = @saved_cs_client */;
 
+DROP TABLE IF EXISTS `mirror_product_baseurls`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `mirror_product_baseurls` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `prefix` varchar(255) NOT NULL,
+  `baseurl_http` varchar(255) NOT NULL DEFAULT '',
+  `baseurl_https` varchar(255) NOT NULL DEFAULT '',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `prefix` (`prefix`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+DROP TABLE IF EXISTS `mirror_location_metadata`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `mirror_location_metadata` (
+  `location_id` int(11) NOT NULL,
+  `language` varchar(30) NOT NULL DEFAULT '',
+  `sha256` char(64) NOT NULL DEFAULT '',
+  `size` bigint(20) NOT NULL DEFAULT '0',
+  `version` varchar(255) NOT NULL DEFAULT '',
+  `signature_path` varchar(255) NOT NULL DEFAULT '',
+  PRIMARY KEY (`location_id`,`language`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+DROP TABLE IF EXISTS `mirror_admin_audit_log`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `mirror_admin_audit_log` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(6) NOT NULL,
+  `actor` varchar(255) NOT NULL,
+  `action` varchar(32) NOT NULL,
+  `resource` varchar(32) NOT NULL,
+  `resource_key` varchar(512) NOT NULL,
+  `old_value` text,
+  `new_value` text,
+  PRIMARY KEY (`id`),
+  KEY `resource_idx` (`resource`,`resource_key`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+DROP TABLE IF EXISTS `mirror_alias_history`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `mirror_alias_history` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `alias` varchar(255) NOT NULL,
+  `old_product` varchar(255) DEFAULT NULL,
+  `new_product` varchar(255) DEFAULT NULL,
+  `changed_at` datetime(6) NOT NULL,
+  `actor` varchar(255) NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `alias_idx` (`alias`,`changed_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+DROP TABLE IF EXISTS `mirror_alias_schedule`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `mirror_alias_schedule` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `alias` varchar(255) NOT NULL,
+  `product` varchar(255) NOT NULL,
+  `activate_at` datetime(6) NOT NULL,
+  `created_at` datetime(6) NOT NULL,
+  `actor` varchar(255) NOT NULL,
+  `promoted_at` datetime(6) DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `alias_idx` (`alias`,`activate_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+DROP TABLE IF EXISTS `mirror_alias_rollouts`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `mirror_alias_rollouts` (
+  `id` int(10) unsigned NOT NULL AUTO_INCREMENT,
+  `alias` varchar(255) NOT NULL,
+  `product` varchar(255) NOT NULL,
+  `percent` tinyint(3) unsigned NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `alias_product` (`alias`,`product`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+DROP TABLE IF EXISTS `mirror_experiments`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `mirror_experiments` (
+  `id` int(10) unsigned NOT NULL AUTO_INCREMENT,
+  `product` varchar(255) NOT NULL,
+  `experiment` varchar(255) NOT NULL,
+  `variant` varchar(255) NOT NULL,
+  `starts_at` datetime(6) NOT NULL,
+  `ends_at` datetime(6) NOT NULL,
+  `traffic_percent` tinyint(3) unsigned DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `product_experiment` (`product`,`experiment`),
+  KEY `variant_idx` (`variant`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
 /*!40103 SET TIME_ZONE=@OLD_TIME_ZONE */;
 /*!40101 SET SQL_MODE=@OLD_SQL_MODE */;
 /*!40014 SET FOREIGN_KEY_CHECKS=@OLD_FOREIGN_KEY_CHECKS */;
@@ -27,6 +27,7 @@ INSERT INTO `mirror_aliases` (`id`, `alias`, `related_product`) VALUES (13,'fire
 INSERT INTO `mirror_aliases` (`id`, `alias`, `related_product`) VALUES (14,'firefox-esr115-latest-ssl','Firefox-115.16.1esr-SSL');
 INSERT INTO `mirror_aliases` (`id`, `alias`, `related_product`) VALUES (15,'firefox-msi-latest-ssl','Firefox-131.0.3-msi-SSL');
 INSERT INTO `mirror_aliases` (`id`, `alias`, `related_product`) VALUES (16,'firefox-beta-msi-latest-ssl','Firefox-132.0b9-msi-SSL');
+INSERT INTO `mirror_aliases` (`id`, `alias`, `related_product`) VALUES (17,'firefox-store-latest-ssl','Firefox-store-SSL');
 /*!40000 ALTER TABLE `mirror_aliases` ENABLE KEYS */;
 UNLOCK TABLES;
 
@@ -139,6 +140,9 @@ INSERT INTO `mirror_locations` (`path`, `product_id`, `os_id`, `id`) VALUES ('/f
 INSERT INTO `mirror_locations` (`path`, `product_id`, `os_id`, `id`) VALUES ('/firefox/nightly/latest-mozilla-central-l10n/firefox-135.0a1.:lang.linux-aarch64.tar.xz',6,6,79);
 INSERT INTO `mirror_locations` (`path`, `product_id`, `os_id`, `id`) VALUES ('/firefox/nightly/latest-mozilla-central-l10n/firefox-135.0a1.:lang.linux-aarch64.tar.xz',7,6,80);
 
+INSERT INTO `mirror_locations` (`path`, `product_id`, `os_id`, `id`) VALUES ('https://apps.microsoft.com/detail/9nzvdkpmr9rd?hl=:lang',29,1,81);
+INSERT INTO `mirror_locations` (`path`, `product_id`, `os_id`, `id`) VALUES ('/firefox/releases/39.0/mac/:lang/Firefox%2039.0.dmg',29,2,82);
+INSERT INTO `mirror_locations` (`path`, `product_id`, `os_id`, `id`) VALUES ('https://evil.example.com/Firefox%20Setup.exe',29,3,83);
 /*!40000 ALTER TABLE `mirror_locations` ENABLE KEYS */;
 UNLOCK TABLES;
 
@@ -194,9 +198,30 @@ INSERT INTO `mirror_products` (`count`, `name`, `checknow`, `priority`, `active`
 INSERT INTO `mirror_products` (`count`, `name`, `checknow`, `priority`, `active`, `id`, `ssl_only`) VALUES (0,'Firefox-nightly-msi-latest-SSL',1,1,1,26,1);
 INSERT INTO `mirror_products` (`count`, `name`, `checknow`, `priority`, `active`, `id`, `ssl_only`) VALUES (0,'Firefox-115.16.1esr-msi-SSL',1,1,1,27,1);
 INSERT INTO `mirror_products` (`count`, `name`, `checknow`, `priority`, `active`, `id`, `ssl_only`) VALUES (0,'Thunderbird-131.0.1-SSL',1,1,1,28,1);
+INSERT INTO `mirror_products` (`count`, `name`, `checknow`, `priority`, `active`, `id`, `ssl_only`) VALUES (0,'Firefox-store-SSL',1,1,1,29,1);
 /*!40000 ALTER TABLE `mirror_products` ENABLE KEYS */;
 UNLOCK TABLES;
 
+LOCK TABLES `mirror_product_baseurls` WRITE;
+/*!40000 ALTER TABLE `mirror_product_baseurls` DISABLE KEYS */;
+INSERT INTO `mirror_product_baseurls` (`id`, `prefix`, `baseurl_http`, `baseurl_https`) VALUES (1,'Thunderbird','download.cdn.thunderbird.net/pub','download-installer.cdn.thunderbird.net/pub');
+/*!40000 ALTER TABLE `mirror_product_baseurls` ENABLE KEYS */;
+UNLOCK TABLES;
+
+LOCK TABLES `mirror_location_metadata` WRITE;
+/*!40000 ALTER TABLE `mirror_location_metadata` DISABLE KEYS */;
+INSERT INTO `mirror_location_metadata` (`location_id`, `language`, `sha256`, `size`, `version`, `signature_path`) VALUES (6,'','',0,'39.0','/firefox/releases/39.0/win32/:lang/Firefox%20Setup%2039.0.exe.asc');
+INSERT INTO `mirror_location_metadata` (`location_id`, `language`, `sha256`, `size`, `version`, `signature_path`) VALUES (6,'en-US','9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08',49542368,'39.0','/firefox/releases/39.0/win32/:lang/Firefox%20Setup%2039.0.exe.asc');
+/*!40000 ALTER TABLE `mirror_location_metadata` ENABLE KEYS */;
+UNLOCK TABLES;
+
+LOCK TABLES `mirror_alias_history` WRITE;
+/*!40000 ALTER TABLE `mirror_alias_history` DISABLE KEYS */;
+INSERT INTO `mirror_alias_history` (`id`, `alias`, `old_product`, `new_product`, `changed_at`, `actor`) VALUES (1,'firefox-beta-latest',NULL,'Firefox-127.0b9','2024-05-01 12:00:00.000000','releng');
+INSERT INTO `mirror_alias_history` (`id`, `alias`, `old_product`, `new_product`, `changed_at`, `actor`) VALUES (2,'firefox-beta-latest','Firefox-127.0b9','Firefox','2024-06-01 12:00:00.000000','releng');
+/*!40000 ALTER TABLE `mirror_alias_history` ENABLE KEYS */;
+UNLOCK TABLES;
+
 /*!40103 SET TIME_ZONE=@OLD_TIME_ZONE */;
 /*!40101 SET SQL_MODE=@OLD_SQL_MODE */;
 /*!40014 SET FOREIGN_KEY_CHECKS=@OLD_FOREIGN_KEY_CHECKS */;
@@ -4,12 +4,14 @@ package main
 
 import (
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"time"
 
 	"github.com/urfave/cli"
 
+	"github.com/mozilla-services/go-bouncer/bouncer"
 	_ "github.com/mozilla-services/go-bouncer/mozlog"
 )
 
@@ -56,12 +58,86 @@ func main() {
 			Usage:  "The base URL for HTTPS products. Scheme should be excluded, e.g. pinned-cdn.mozilla.com/pub",
 			EnvVar: "BOUNCER_PINNED_BASEURL_HTTPS",
 		},
+		cli.StringFlag{
+			Name:   "config-file",
+			Usage:  "Optional. Path to a JSON config file for structured settings, e.g. attribution policies",
+			EnvVar: "BOUNCER_CONFIG_FILE",
+		},
 		cli.StringFlag{
 			Name:   "stub-root-url",
 			Value:  "",
 			Usage:  "Optional. Root URL of the stubattribution service, e.g. https://stubdownloader.services.mozilla.com/",
 			EnvVar: "BOUNCER_STUB_ROOT_URL",
 		},
+		cli.DurationFlag{
+			Name:   "stub-health-interval",
+			Value:  0,
+			Usage:  "Optional. Interval between health checks of the stubattribution services, e.g. 10s. Health checks are disabled when 0",
+			EnvVar: "BOUNCER_STUB_HEALTH_INTERVAL",
+		},
+		cli.DurationFlag{
+			Name:   "stub-health-timeout",
+			Value:  2 * time.Second,
+			Usage:  "Timeout of the health checks of the stubattribution services",
+			EnvVar: "BOUNCER_STUB_HEALTH_TIMEOUT",
+		},
+		cli.DurationFlag{
+			Name:   "alias-promote-interval",
+			Value:  time.Minute,
+			Usage:  "Interval at which due scheduled alias switches are promoted to mirror_aliases. Until then, a due switch takes precedence over later changes to mirror_aliases. Promotion is disabled when 0",
+			EnvVar: "BOUNCER_ALIAS_PROMOTE_INTERVAL",
+		},
+		cli.StringFlag{
+			Name:   "rollout-key-header",
+			Usage:  "Optional. Name of a header holding a stable key of the client, e.g. X-Rollout-Key, that clients are bucketed by for alias rollouts. Clients are bucketed by IP address, from X-Forwarded-For behind a proxy, and user agent otherwise",
+			EnvVar: "BOUNCER_ROLLOUT_KEY_HEADER",
+		},
+		cli.BoolTFlag{
+			Name:   "respect-gpc",
+			Usage:  "Honour the Global Privacy Control signal (Sec-GPC: 1): no attribution and no referer or user agent in log lines",
+			EnvVar: "BOUNCER_RESPECT_GPC",
+		},
+		cli.BoolFlag{
+			Name:   "respect-dnt",
+			Usage:  "Honour the Do Not Track signal (DNT: 1): no attribution and no referer or user agent in log lines",
+			EnvVar: "BOUNCER_RESPECT_DNT",
+		},
+		cli.StringFlag{
+			Name:   "attribution-sig-verification",
+			Value:  bouncer.AttributionSigOff,
+			Usage:  "Verification of attribution_sig before redirecting to the stubattribution service: off, report or enforce",
+			EnvVar: "BOUNCER_ATTRIBUTION_SIG_VERIFICATION",
+		},
+		cli.StringSliceFlag{
+			Name:   "attribution-key-files",
+			Usage:  "Files containing the HMAC keys used to verify attribution_sig, one key per file",
+			EnvVar: "BOUNCER_ATTRIBUTION_KEY_FILES",
+		},
+		cli.StringSliceFlag{
+			Name:   "absolute-location-hosts",
+			Usage:  "Optional. Hosts that locations holding an absolute URL are allowed to point to, e.g. apps.microsoft.com",
+			EnvVar: "BOUNCER_ABSOLUTE_LOCATION_HOSTS",
+		},
+		cli.StringSliceFlag{
+			Name:   "redirect-allowed-hosts",
+			Usage:  "Optional. Extra hosts that bouncer may redirect to",
+			EnvVar: "BOUNCER_REDIRECT_ALLOWED_HOSTS",
+		},
+		cli.BoolFlag{
+			Name:   "digest-headers",
+			Usage:  "Add Repr-Digest and Digest headers to redirects to builds with a known SHA-256",
+			EnvVar: "BOUNCER_DIGEST_HEADERS",
+		},
+		cli.StringFlag{
+			Name:   "grpc-addr",
+			Usage:  "Optional. Address on which to serve the gRPC interface, e.g. :9090",
+			EnvVar: "BOUNCER_GRPC_ADDR",
+		},
+		cli.StringFlag{
+			Name:   "admin-addr",
+			Usage:  "Optional. Private address on which to serve the admin API, e.g. 127.0.0.1:8889. Tokens are set in the config file",
+			EnvVar: "BOUNCER_ADMIN_ADDR",
+		},
 	}
 	if err := app.Run(os.Args); err != nil {
 		log.Fatal(err)
@@ -81,7 +157,7 @@ func versionHandler(w http.ResponseWriter, _ *http.Request) {
 
 // Main is the entrypoint of the application.
 func Main(c *cli.Context) {
-	db, err := NewDB(c.String("db-dsn"))
+	db, err := bouncer.NewDB(c.String("db-dsn"))
 	if err != nil {
 		log.Fatalf("Could not open DB: %v", err)
 	}
@@ -96,26 +172,87 @@ func Main(c *cli.Context) {
 		log.Fatal("BOUNCER_PINNED_BASEURL_HTTPS must be set")
 	}
 
-	bouncerHandler := &BouncerHandler{
-		db:                 db,
+	config, err := bouncer.LoadConfig(c.String("config-file"))
+	if err != nil {
+		log.Fatalf("Could not load config file: %v", err)
+	}
+
+	attributionSigVerification := c.String("attribution-sig-verification")
+	switch attributionSigVerification {
+	case bouncer.AttributionSigOff, bouncer.AttributionSigReport, bouncer.AttributionSigEnforce:
+	default:
+		log.Fatalf("Invalid BOUNCER_ATTRIBUTION_SIG_VERIFICATION: %s", attributionSigVerification)
+	}
+
+	attributionKeys, err := bouncer.LoadAttributionKeys(c.StringSlice("attribution-key-files"))
+	if err != nil {
+		log.Fatalf("Could not load attribution keys: %v", err)
+	}
+	if attributionSigVerification != bouncer.AttributionSigOff && len(attributionKeys) == 0 {
+		log.Fatal("BOUNCER_ATTRIBUTION_KEY_FILES must be set to verify attribution signatures")
+	}
+
+	bouncerHandler := bouncer.NewBouncerHandler(db, bouncer.Options{
 		CacheTime:          time.Duration(c.Int("cache-time")) * time.Second,
 		PinHTTPSHeaderName: c.String("pin-https-header-name"),
 		PinnedBaseURLHttp:  c.String("pinned-baseurl-http"),
 		PinnedBaseURLHttps: c.String("pinned-baseurl-https"),
 		StubRootURL:        c.String("stub-root-url"),
+
+		RespectGPC:                 c.BoolT("respect-gpc"),
+		RespectDNT:                 c.Bool("respect-dnt"),
+		AttributionSigVerification: attributionSigVerification,
+		AttributionKeys:            attributionKeys,
+		AttributionPolicies:        config.AttributionPolicies,
+		StubBackends:               config.StubBackends,
+		TrustedSites:               config.TrustedSites,
+		KindRules:                  config.KindRules,
+		AbsoluteLocationHosts:      c.StringSlice("absolute-location-hosts"),
+		RedirectAllowedHosts:       c.StringSlice("redirect-allowed-hosts"),
+		DigestHeaders:              c.Bool("digest-headers"),
+		RolloutKeyHeader:           c.String("rollout-key-header"),
+	})
+
+	if interval := c.Duration("stub-health-interval"); interval > 0 {
+		bouncerHandler.StartStubHealthChecker(interval, c.Duration("stub-health-timeout"))
 	}
 
-	healthHandler := &HealthHandler{
-		db:        db,
-		CacheTime: 5 * time.Second,
+	if interval := c.Duration("alias-promote-interval"); interval > 0 {
+		db.StartAliasPromoter(interval)
 	}
 
+	healthHandler := bouncer.NewHealthHandler(db, bouncerHandler.StubHealth, 5*time.Second)
+
 	mux := http.NewServeMux()
 
 	mux.Handle("/__lbheartbeat__", healthHandler)
 	mux.Handle("/__heartbeat__", healthHandler)
 	mux.HandleFunc("/__version__", versionHandler)
-	mux.Handle("/", bouncerHandler)
+	mux.HandleFunc("/__metrics__", bouncer.MetricsHandler)
+	bouncerHandler.RegisterRoutes(mux)
+
+	if grpcAddr := c.String("grpc-addr"); grpcAddr != "" {
+		listener, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			log.Fatalf("Could not listen on %s: %v", grpcAddr, err)
+		}
+		go func() {
+			log.Fatal(bouncer.NewGRPCServer(bouncerHandler).Serve(listener))
+		}()
+	}
+
+	if adminAddr := c.String("admin-addr"); adminAddr != "" {
+		if len(config.AdminTokens) == 0 {
+			log.Fatal("admin_tokens must be set in the config file to serve the admin API")
+		}
+		adminServer := &http.Server{
+			Addr:    adminAddr,
+			Handler: bouncer.NewAdminHandler(bouncerHandler, config.AdminTokens),
+		}
+		go func() {
+			log.Fatal(adminServer.ListenAndServe())
+		}()
+	}
 
 	server := &http.Server{
 		Addr:    c.String("addr"),